@@ -0,0 +1,128 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"pcd-pc4/internal/content"
+	"pcd-pc4/internal/hybrid"
+	"pcd-pc4/internal/knn"
+)
+
+// -----------------------------------------------------------
+// Cold start: usuarios desconocidos o con pocos ratings
+// -----------------------------------------------------------
+
+const (
+	ColdStartUnknown = "unknown" // sin ratings: sólo popularidad
+	ColdStartSparse  = "sparse"  // pocos ratings: personalizado + popularidad
+)
+
+var (
+	// Por debajo de este número de ratings se mezcla con popularidad
+	// (COLD_START_MIN_RATINGS; 0 = desactivado)
+	coldStartMinRatings = 5
+
+	movieGenres map[string][]string
+)
+
+func loadColdStart() {
+	if v := os.Getenv("COLD_START_MIN_RATINGS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatal("COLD_START_MIN_RATINGS inválido: ", v)
+		}
+		coldStartMinRatings = n
+	}
+
+	movieGenres = content.LoadMovieGenres("data/clean/movies.csv")
+}
+
+// parseGenres acepta "Comedy,Drama" o "Comedy|Drama" (sin distinguir mayúsculas)
+func parseGenres(v string) []string {
+	var genres []string
+	for _, g := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '|' }) {
+		if g = strings.ToLower(strings.TrimSpace(g)); g != "" {
+			genres = append(genres, g)
+		}
+	}
+	return genres
+}
+
+// genreFilter: nil si no se pidieron géneros; si no, acepta las películas
+// con al menos uno de ellos.
+func genreFilter(genres []string) func(movie string) bool {
+	if len(genres) == 0 {
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(genres))
+	for _, g := range genres {
+		wanted[g] = true
+	}
+
+	return func(movie string) bool {
+		for _, g := range movieGenres[movie] {
+			if wanted[g] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// recommendPopular: para usuarios sin historial, las películas con mejor
+// promedio bayesiano (filtradas por géneros si se pidieron).
+func recommendPopular(user string, opts recommendOptions) RecommendResponse {
+	return RecommendResponse{
+		UserID:          user,
+		Model:           opts.model,
+		ColdStart:       ColdStartUnknown,
+		Recommendations: popularityModel.Top(opts.n, nil, genreFilter(opts.genres)),
+	}
+}
+
+// blendWithPopular mezcla por posición (reciprocal rank fusion) la
+// recomendación personalizada de un usuario con pocos ratings y las
+// populares; el peso personalizado crece con su historial (ratings, y con
+// model=bpr también interacciones) hasta el umbral.
+func blendWithPopular(resp RecommendResponse, user string, opts recommendOptions) (RecommendResponse, error) {
+	seen := historyOf(user, opts.model)
+	alpha := float64(len(seen)) / float64(coldStartMinRatings)
+
+	components := map[string]map[string]float64{
+		hybrid.CompPersonalized: {},
+		hybrid.CompPopularity:   {},
+	}
+	candidates := []string{}
+
+	for _, r := range resp.Recommendations {
+		components[hybrid.CompPersonalized][r.MovieID] = r.Predicted
+		candidates = append(candidates, r.MovieID)
+	}
+	for _, r := range popularityModel.Top(opts.n, seen, genreFilter(opts.genres)) {
+		components[hybrid.CompPopularity][r.MovieID] = hybrid.Scale(hybrid.CompPopularity, r.Predicted)
+		if _, dup := components[hybrid.CompPersonalized][r.MovieID]; !dup {
+			candidates = append(candidates, r.MovieID)
+		}
+	}
+
+	weights := hybrid.Weights{
+		hybrid.CompPersonalized: alpha,
+		hybrid.CompPopularity:   1 - alpha,
+	}
+
+	scored, err := hybrid.Blend(candidates, components, weights, hybrid.StrategyRank, opts.n)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.ColdStart = ColdStartSparse
+	resp.Recommendations = make([]knn.Recommended, 0, len(scored))
+	for _, s := range scored {
+		resp.Recommendations = append(resp.Recommendations, knn.Recommended{MovieID: s.MovieID, Predicted: s.Score})
+	}
+	return resp, nil
+}
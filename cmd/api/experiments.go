@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/pkg/database"
+)
+
+// -----------------------------------------------------------
+// Experimentos A/B: cada usuario cae siempre en el mismo brazo
+// (hash del experimento y el usuario) y se le atiende con la
+// configuración de ese brazo
+// -----------------------------------------------------------
+
+// Brazo: los campos vacíos dejan el valor por defecto del API
+type experimentArm struct {
+	Name       string `json:"name"`
+	Weight     int    `json:"weight"` // proporción de usuarios
+	Model      string `json:"model,omitempty"`
+	Similarity string `json:"similarity,omitempty"`
+	K          int    `json:"k,omitempty"`
+}
+
+type experiment struct {
+	Name string          `json:"name"`
+	Arms []experimentArm `json:"arms"`
+
+	totalWeight int
+}
+
+// Experimento activo (EXPERIMENT_PATH; nil = sin experimento)
+var activeExperiment *experiment
+
+// loadExperiment lee un JSON como
+//
+//	{"name": "similitud", "arms": [
+//	  {"name": "control", "weight": 50},
+//	  {"name": "pearson", "weight": 50, "similarity": "pearson", "k": 30}]}
+func loadExperiment() {
+	path := os.Getenv("EXPERIMENT_PATH")
+	if path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("Error leyendo EXPERIMENT_PATH: ", err)
+	}
+
+	var exp experiment
+	if err := json.Unmarshal(data, &exp); err != nil {
+		log.Fatal("Experimento inválido en ", path, ": ", err)
+	}
+	if err := exp.validate(); err != nil {
+		log.Fatal("Experimento inválido en ", path, ": ", err)
+	}
+
+	activeExperiment = &exp
+	fmt.Println("Experimento", exp.Name, "activo con", len(exp.Arms), "brazos")
+}
+
+func (e *experiment) validate() error {
+	if e.Name == "" || len(e.Arms) < 2 {
+		return fmt.Errorf("se requiere nombre y al menos dos brazos")
+	}
+
+	seen := map[string]bool{}
+	for _, arm := range e.Arms {
+		if arm.Name == "" || seen[arm.Name] {
+			return fmt.Errorf("brazo sin nombre o repetido: %q", arm.Name)
+		}
+		seen[arm.Name] = true
+
+		if arm.Weight < 1 {
+			return fmt.Errorf("brazo %s: weight debe ser mayor que 0", arm.Name)
+		}
+		e.totalWeight += arm.Weight
+
+		switch arm.Model {
+		case "", ModelUserKNN, ModelItemKNN, ModelMF, ModelBPR, ModelContent, ModelHybrid:
+		default:
+			return fmt.Errorf("brazo %s: modelo desconocido %s", arm.Name, arm.Model)
+		}
+		if arm.Similarity != "" {
+			if _, err := knn.NewSimilarity(arm.Similarity, nil); err != nil {
+				return fmt.Errorf("brazo %s: %v", arm.Name, err)
+			}
+		}
+		if arm.K < 0 || arm.K > MaxK {
+			return fmt.Errorf("brazo %s: k no puede superar %d", arm.Name, MaxK)
+		}
+	}
+	return nil
+}
+
+// assign elige el brazo del usuario de forma estable: mientras no cambie
+// el experimento, el mismo usuario recibe siempre el mismo brazo
+func (e *experiment) assign(user string) experimentArm {
+	h := fnv.New64a()
+	h.Write([]byte(e.Name + "/" + user))
+	bucket := int(h.Sum64() % uint64(e.totalWeight))
+
+	for _, arm := range e.Arms {
+		if bucket < arm.Weight {
+			return arm
+		}
+		bucket -= arm.Weight
+	}
+	return e.Arms[len(e.Arms)-1]
+}
+
+// applyExperiment aplica el brazo del usuario a opts. Si la petición fija
+// modelo, similitud o K queda fuera del experimento para no mezclar
+// configuraciones dentro de un brazo.
+func applyExperiment(user string, q url.Values, opts *recommendOptions) {
+	if activeExperiment == nil {
+		return
+	}
+	if q.Get("model") != "" || q.Get("similarity") != "" || q.Get("k") != "" {
+		return
+	}
+
+	arm := activeExperiment.assign(user)
+	if arm.Model != "" {
+		opts.model = arm.Model
+	}
+	if arm.Similarity != "" {
+		opts.similarity = arm.Similarity
+	}
+	if arm.K > 0 {
+		opts.k = arm.K
+	}
+
+	opts.experiment = activeExperiment.Name
+	opts.arm = arm.Name
+}
+
+// -----------------------------------------------------------
+// ENDPOINT: GET /experiments (configuración activa)
+// -----------------------------------------------------------
+
+func handleExperiment(w http.ResponseWriter, r *http.Request) {
+	if activeExperiment == nil {
+		http.Error(w, "No hay experimento activo", 404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activeExperiment)
+}
+
+// -----------------------------------------------------------
+// ENDPOINT: GET /experiments/report[?experiment=&since_hours=]
+// Latencia y feedback por brazo a partir de MongoDB. Las respuestas
+// de cold start (populares, iguales en todos los brazos) no entran en
+// la comparación; sólo se cuentan en cold_start.
+// -----------------------------------------------------------
+
+type ArmReport struct {
+	Arm       string `json:"arm"`
+	Requests  int    `json:"requests"`
+	Users     int    `json:"users"`
+	ColdStart int    `json:"cold_start"` // respuestas de cold start, fuera del resto
+
+	LatencyMeanMS float64 `json:"latency_mean_ms"`
+	LatencyP50MS  int64   `json:"latency_p50_ms"`
+	LatencyP95MS  int64   `json:"latency_p95_ms"`
+	LatencyP99MS  int64   `json:"latency_p99_ms"`
+
+	ArmFeedback // de las películas mostradas por el brazo
+}
+
+type ExperimentReport struct {
+	Experiment string      `json:"experiment"`
+	Since      int64       `json:"since,omitempty"`
+	Arms       []ArmReport `json:"arms"`
+}
+
+func handleExperimentReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	name := q.Get("experiment")
+	if name == "" {
+		if activeExperiment == nil {
+			http.Error(w, "No hay experimento activo; indique ?experiment=", 400)
+			return
+		}
+		name = activeExperiment.Name
+	}
+
+	var since int64
+	if v := q.Get("since_hours"); v != "" {
+		h, err := strconv.Atoi(v)
+		if err != nil || h < 1 {
+			http.Error(w, "since_hours inválido: "+v, 400)
+			return
+		}
+		since = time.Now().Add(-time.Duration(h) * time.Hour).Unix()
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	report, err := experimentReport(ctx, name, since)
+	if err != nil {
+		http.Error(w, "Error consultando MongoDB: "+err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func experimentReport(ctx context.Context, name string, since int64) (ExperimentReport, error) {
+	report := ExperimentReport{Experiment: name, Since: since}
+	filter := bson.M{"experiment": name, "cold_start": bson.M{"$exists": false}}
+
+	// El feedback cuenta según cuándo se sirvió la recomendación, no
+	// cuándo llegó: una recomendación de la ventana suma todo su feedback
+	fbFilter := bson.M{"experiment": name, "cold_start": bson.M{"$exists": false}}
+	if since > 0 {
+		filter["timestamp"] = bson.M{"$gte": since}
+		fbFilter["recommended_at"] = bson.M{"$gte": since}
+	}
+
+	// Agrupar en MongoDB: primero por brazo y usuario (usuarios
+	// distintos), luego por brazo
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      bson.M{"arm": "$arm", "user": "$user_id"},
+			"requests": bson.M{"$sum": 1},
+			"latency":  bson.M{"$sum": "$latency_ms"},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$_id.arm",
+			"users":    bson.M{"$sum": 1},
+			"requests": bson.M{"$sum": "$requests"},
+			"latency":  bson.M{"$sum": "$latency"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cur, err := database.RecsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return report, err
+	}
+
+	var rows []struct {
+		Arm      string `bson:"_id"`
+		Users    int    `bson:"users"`
+		Requests int    `bson:"requests"`
+		Latency  int64  `bson:"latency"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return report, err
+	}
+
+	feedback, err := armFeedback(ctx, fbFilter)
+	if err != nil {
+		return report, err
+	}
+	coldStart, err := coldStartByArm(ctx, name, since)
+	if err != nil {
+		return report, err
+	}
+	percentiles, err := latencyPercentiles(ctx, filter)
+	if err != nil {
+		return report, err
+	}
+
+	for _, row := range rows {
+		arm := ArmReport{
+			Arm:           row.Arm,
+			Requests:      row.Requests,
+			Users:         row.Users,
+			LatencyMeanMS: float64(row.Latency) / float64(row.Requests),
+			ColdStart:     coldStart[row.Arm],
+			ArmFeedback:   feedback[row.Arm],
+		}
+		delete(coldStart, row.Arm)
+
+		p := percentiles[row.Arm]
+		arm.LatencyP50MS, arm.LatencyP95MS, arm.LatencyP99MS = p[0], p[1], p[2]
+
+		report.Arms = append(report.Arms, arm)
+	}
+
+	// Brazos que sólo sirvieron cold start
+	for arm, n := range coldStart {
+		report.Arms = append(report.Arms, ArmReport{Arm: arm, ColdStart: n})
+	}
+	sort.Slice(report.Arms, func(i, j int) bool { return report.Arms[i].Arm < report.Arms[j].Arm })
+	return report, nil
+}
+
+// coldStartByArm cuenta por brazo las respuestas de cold start
+func coldStartByArm(ctx context.Context, name string, since int64) (map[string]int, error) {
+	filter := bson.M{"experiment": name, "cold_start": bson.M{"$exists": true}}
+	if since > 0 {
+		filter["timestamp"] = bson.M{"$gte": since}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": "$arm", "n": bson.M{"$sum": 1}}}},
+	}
+
+	cur, err := database.RecsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Arm string `bson:"_id"`
+		N   int    `bson:"n"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, r := range rows {
+		counts[r.Arm] = r.N
+	}
+	return counts, nil
+}
+
+// latencyPercentiles devuelve por brazo p50, p95 y p99 de la latencia
+// (rango más cercano) de las recomendaciones de filter. MongoDB 6 no
+// tiene $percentile: una sola agregación cuenta las recomendaciones de
+// cada latencia (en ms hay pocos valores distintos) y el histograma,
+// ordenado, se recorre acá.
+func latencyPercentiles(ctx context.Context, filter bson.M) (map[string][3]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"arm": "$arm", "latency": "$latency_ms"},
+			"n":   bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.arm", Value: 1}, {Key: "_id.latency", Value: 1}}}},
+	}
+
+	cur, err := database.RecsCollection().Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		ID struct {
+			Arm     string `bson:"arm"`
+			Latency int64  `bson:"latency"`
+		} `bson:"_id"`
+		N int `bson:"n"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	out := map[string][3]int64{}
+	for start := 0; start < len(rows); {
+		arm := rows[start].ID.Arm
+		end, total := start, 0
+		for ; end < len(rows) && rows[end].ID.Arm == arm; end++ {
+			total += rows[end].N
+		}
+
+		var pct [3]int64
+		for k, p := range []float64{0.50, 0.95, 0.99} {
+			// posición (desde 1) del percentil entre las total ordenadas
+			rank := int(p*float64(total) + 0.5)
+			if rank < 1 {
+				rank = 1
+			}
+			seen := 0
+			for _, r := range rows[start:end] {
+				seen += r.N
+				if seen >= rank {
+					pct[k] = r.ID.Latency
+					break
+				}
+			}
+		}
+		out[arm] = pct
+		start = end
+	}
+	return out, nil
+}
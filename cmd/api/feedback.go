@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"pcd-pc4/pkg/database"
+)
+
+// -----------------------------------------------------------
+// Feedback sobre recomendaciones servidas: impresiones, clics,
+// vistas y ratings ligados al recommendation_id de /recommend
+// -----------------------------------------------------------
+
+const (
+	// Eventos máximos por POST /feedback
+	feedbackMaxEvents = 1000
+
+	// Escala de ratings de MovieLens
+	MinRating = 0.5
+	MaxRating = 5.0
+)
+
+type FeedbackEvent struct {
+	MovieID string  `json:"movie_id"`
+	Event   string  `json:"event"`            // impression, click, watch o rating
+	Rating  float64 `json:"rating,omitempty"` // sólo event=rating
+}
+
+// FeedbackRequest acepta un evento suelto (movie_id, event, rating) o
+// una lista en events
+type FeedbackRequest struct {
+	RecommendationID string `json:"recommendation_id"`
+	UserID           string `json:"user_id"`
+
+	FeedbackEvent
+	Events []FeedbackEvent `json:"events,omitempty"`
+}
+
+func validRating(r float64) bool {
+	return r >= MinRating && r <= MaxRating
+}
+
+// -----------------------------------------------------------
+// ENDPOINT: POST /feedback
+// -----------------------------------------------------------
+
+func handleFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", 405)
+		return
+	}
+
+	var req FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Cuerpo inválido", 400)
+		return
+	}
+
+	events := req.Events
+	if req.Event != "" {
+		events = append(events, req.FeedbackEvent)
+	}
+	if len(events) == 0 || len(events) > feedbackMaxEvents {
+		http.Error(w, fmt.Sprintf("Se requieren entre 1 y %d eventos", feedbackMaxEvents), 400)
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(req.RecommendationID)
+	if err != nil {
+		http.Error(w, "recommendation_id inválido", 400)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// La recomendación aporta el usuario, el modelo, el cold start y el brazo
+	var rec database.RecommendationDocument
+	err = database.RecsCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&rec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Recomendación no encontrada", 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error consultando MongoDB: "+err.Error(), 500)
+		return
+	}
+	if req.UserID != "" && req.UserID != rec.UserID {
+		http.Error(w, "La recomendación pertenece a otro usuario", 403)
+		return
+	}
+
+	served := make(map[string]bool, len(rec.Recommended))
+	for _, item := range rec.Recommended {
+		served[item.MovieID] = true
+	}
+
+	now := time.Now().Unix()
+	docs := make([]any, 0, len(events))
+
+	for _, ev := range events {
+		if !served[ev.MovieID] {
+			http.Error(w, "La película "+ev.MovieID+" no está en la recomendación", 400)
+			return
+		}
+
+		switch ev.Event {
+		case database.EventImpression, database.EventClick, database.EventWatch:
+			ev.Rating = 0
+		case database.EventRating:
+			if !validRating(ev.Rating) {
+				http.Error(w, fmt.Sprintf("rating debe estar entre %g y %g", MinRating, MaxRating), 400)
+				return
+			}
+		default:
+			http.Error(w, "Evento desconocido: "+ev.Event, 400)
+			return
+		}
+
+		docs = append(docs, database.FeedbackDocument{
+			RecommendationID: id,
+			UserID:           rec.UserID,
+			MovieID:          ev.MovieID,
+			Event:            ev.Event,
+			Rating:           ev.Rating,
+			Model:            rec.Model,
+			ColdStart:        rec.ColdStart,
+			Experiment:       rec.Experiment,
+			Arm:              rec.Arm,
+			RecommendedAt:    rec.TimestampUnix,
+			TimestampUnix:    now,
+		})
+	}
+
+	if _, err := database.FeedbackCollection().InsertMany(ctx, docs); err != nil {
+		http.Error(w, "Error guardando feedback: "+err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(map[string]int{"recorded": len(docs)})
+}
+
+// -----------------------------------------------------------
+// ENDPOINT: GET /feedback/report[?days=7]
+// CTR y conversión (vistas + ratings) por día (UTC) y modelo; las
+// recomendaciones de cold start van en filas aparte (cold_start) para
+// no mezclar las populares con lo que produjo el modelo
+// -----------------------------------------------------------
+
+// EventCounts: eventos de feedback y tasas derivadas
+type EventCounts struct {
+	Impressions int `json:"impressions" bson:"impressions"`
+	Clicks      int `json:"clicks" bson:"clicks"`
+	Watches     int `json:"watches" bson:"watches"`
+	Ratings     int `json:"ratings" bson:"ratings"`
+
+	CTR        float64 `json:"ctr" bson:"-"`        // clics / impresiones
+	Conversion float64 `json:"conversion" bson:"-"` // (vistas + ratings) / impresiones
+}
+
+func (c *EventCounts) computeRates() {
+	if c.Impressions > 0 {
+		c.CTR = float64(c.Clicks) / float64(c.Impressions)
+		c.Conversion = float64(c.Watches+c.Ratings) / float64(c.Impressions)
+	}
+}
+
+// eventCounts: acumuladores de $group para EventCounts (y la suma de
+// ratings para la media)
+func eventCounts() bson.M {
+	count := func(event string) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$event", event}}, 1, 0}}}
+	}
+	return bson.M{
+		"impressions": count(database.EventImpression),
+		"clicks":      count(database.EventClick),
+		"watches":     count(database.EventWatch),
+		"ratings":     count(database.EventRating),
+		"rating_sum":  bson.M{"$sum": "$rating"},
+	}
+}
+
+type FeedbackReportRow struct {
+	Day       string `json:"day"`
+	Model     string `json:"model"`
+	ColdStart string `json:"cold_start,omitempty"`
+
+	Served int `json:"served"` // recomendaciones servidas
+	EventCounts
+}
+
+func handleFeedbackReport(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 365 {
+			http.Error(w, "days debe estar entre 1 y 365: "+v, 400)
+			return
+		}
+		days = n
+	}
+
+	// Desde el inicio del día UTC de hace days-1 días
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(days - 1)).Unix()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	rows, err := feedbackReport(ctx, since)
+	if err != nil {
+		http.Error(w, "Error consultando MongoDB: "+err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// Día UTC (2006-01-02) del timestamp unix de un documento
+var dayOfTimestamp = bson.M{"$dateToString": bson.M{
+	"format": "%Y-%m-%d",
+	"date":   bson.M{"$toDate": bson.M{"$multiply": bson.A{"$timestamp", 1000}}},
+}}
+
+// feedbackReport agrupa en MongoDB recomendaciones y eventos por día,
+// modelo y cold start; sólo las filas agregadas llegan al API.
+func feedbackReport(ctx context.Context, since int64) ([]FeedbackReportRow, error) {
+	type key struct {
+		Day       string `bson:"day"`
+		Model     string `bson:"model"`
+		ColdStart string `bson:"cold_start"`
+	}
+	type row struct {
+		Key         key `bson:"_id"`
+		Served      int `bson:"served"`
+		EventCounts `bson:",inline"`
+	}
+
+	group := func(col *mongo.Collection, acc bson.M) ([]row, error) {
+		acc["_id"] = bson.M{"day": dayOfTimestamp, "model": "$model", "cold_start": "$cold_start"}
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": since}}}},
+			{{Key: "$group", Value: acc}},
+		}
+
+		cur, err := col.Aggregate(ctx, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		var rows []row
+		err = cur.All(ctx, &rows)
+		return rows, err
+	}
+
+	served, err := group(database.RecsCollection(), bson.M{"served": bson.M{"$sum": 1}})
+	if err != nil {
+		return nil, err
+	}
+	events, err := group(database.FeedbackCollection(), eventCounts())
+	if err != nil {
+		return nil, err
+	}
+
+	rows := map[key]*FeedbackReportRow{}
+	rowOf := func(k key) *FeedbackReportRow {
+		if rows[k] == nil {
+			rows[k] = &FeedbackReportRow{Day: k.Day, Model: k.Model, ColdStart: k.ColdStart}
+		}
+		return rows[k]
+	}
+	for _, r := range served {
+		rowOf(r.Key).Served = r.Served
+	}
+	for _, r := range events {
+		rowOf(r.Key).EventCounts = r.EventCounts
+	}
+
+	report := make([]FeedbackReportRow, 0, len(rows))
+	for _, row := range rows {
+		row.computeRates()
+		report = append(report, *row)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Day != report[j].Day {
+			return report[i].Day < report[j].Day
+		}
+		if report[i].Model != report[j].Model {
+			return report[i].Model < report[j].Model
+		}
+		return report[i].ColdStart < report[j].ColdStart
+	})
+	return report, nil
+}
+
+// -----------------------------------------------------------
+// Feedback por brazo para GET /experiments/report
+// -----------------------------------------------------------
+
+type ArmFeedback struct {
+	EventCounts
+	MeanRating float64 `json:"mean_rating"` // de los ratings recibidos
+}
+
+// armFeedback agrupa por brazo el feedback que cumple filter (experimento
+// y ventana de tiempo sobre recommended_at)
+func armFeedback(ctx context.Context, filter bson.M) (map[string]ArmFeedback, error) {
+	acc := eventCounts()
+	acc["_id"] = "$arm"
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: acc}},
+	}
+
+	cur, err := database.FeedbackCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Arm         string  `bson:"_id"`
+		RatingSum   float64 `bson:"rating_sum"`
+		EventCounts `bson:",inline"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	arms := make(map[string]ArmFeedback, len(rows))
+	for _, r := range rows {
+		fb := ArmFeedback{EventCounts: r.EventCounts}
+		fb.computeRates()
+		if fb.Ratings > 0 {
+			fb.MeanRating = r.RatingSum / float64(fb.Ratings)
+		}
+		arms[r.Arm] = fb
+	}
+	return arms, nil
+}
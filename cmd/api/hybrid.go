@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"pcd-pc4/internal/hybrid"
+	"pcd-pc4/internal/knn"
+)
+
+var (
+	// Pesos aprendidos por cmd/entrenar (TRAIN_MODEL=hybrid); nil = sin ajustar
+	learnedWeights hybrid.Weights
+
+	defaultStrategy = hybrid.StrategyWeighted
+)
+
+// loadHybridWeights lee los pesos ajustados en validación y la estrategia
+// por defecto (HYBRID_STRATEGY)
+func loadHybridWeights() {
+	if s := os.Getenv("HYBRID_STRATEGY"); s != "" {
+		if err := hybrid.CheckStrategy(s); err != nil {
+			fmt.Println("HYBRID_STRATEGY ignorada:", err)
+		} else {
+			defaultStrategy = s
+		}
+	}
+
+	path := os.Getenv("HYBRID_WEIGHTS_PATH")
+	if path == "" {
+		path = "models/hybrid.json"
+	}
+
+	fitted, err := hybrid.LoadFitted(path)
+	if err != nil {
+		fmt.Println("Pesos híbridos por defecto (sin ajustar):", err)
+		return
+	}
+
+	learnedWeights = fitted.Weights
+	fmt.Println("Pesos híbridos aprendidos:", fitted.Weights, "RMSE validación", fitted.RMSE)
+}
+
+// parseHybridOptions: strategy=weighted|rank, weights=default|learned y
+// w_<componente> para sobreescribir un peso concreto.
+func parseHybridOptions(q url.Values, opts *recommendOptions) error {
+	opts.strategy = defaultStrategy
+	if v := q.Get("strategy"); v != "" {
+		if err := hybrid.CheckStrategy(v); err != nil {
+			return err
+		}
+		opts.strategy = v
+	}
+
+	base := learnedWeights
+	switch v := q.Get("weights"); v {
+	case "":
+		if base == nil {
+			base = hybrid.DefaultWeights()
+		}
+	case "default":
+		base = hybrid.DefaultWeights()
+	case "learned":
+		if base == nil {
+			return fmt.Errorf("no hay pesos híbridos aprendidos")
+		}
+	default:
+		return fmt.Errorf("weights debe ser default o learned: %s", v)
+	}
+
+	opts.weights = hybrid.Weights{}
+	for name, w := range base {
+		opts.weights[name] = w
+	}
+
+	for _, name := range []string{hybrid.CompKNN, hybrid.CompContent, hybrid.CompPopularity} {
+		v := q.Get("w_" + name)
+		if v == "" {
+			continue
+		}
+		w, err := strconv.ParseFloat(v, 64)
+		if err != nil || w < 0 {
+			return fmt.Errorf("w_%s inválido: %s", name, v)
+		}
+		opts.weights[name] = w
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------
+// model=hybrid: KNN distribuido + contenido + popularidad
+// -----------------------------------------------------------
+
+func recommendHybrid(ctx context.Context, user string, opts recommendOptions) (RecommendResponse, error) {
+	resp := RecommendResponse{UserID: user, Model: ModelHybrid, Strategy: opts.strategy}
+
+	// KNN user-based en los nodos; si no responde se combina el resto
+	knnOpts := opts
+	knnOpts.model = ModelUserKNN
+	knnOpts.n = hybrid.Pool
+
+	knnResp, err := distributedRecommendation(ctx, user, knnOpts)
+	resp.Similarity = knnResp.Similarity
+	resp.MissingShards = knnResp.MissingShards
+	resp.Degraded = knnResp.Degraded
+	if err != nil {
+		fmt.Println("Híbrido sin componente KNN para", user, ":", err)
+		resp.Degraded = true
+	}
+
+	scored, err := hybrid.Rank(knnResp.Recommendations, ratingsOf(user), contentModel, popularityModel,
+		opts.weights, opts.strategy, opts.n)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Components = scored
+	resp.Recommendations = make([]knn.Recommended, 0, len(scored))
+	for _, s := range scored {
+		resp.Recommendations = append(resp.Recommendations, knn.Recommended{MovieID: s.MovieID, Predicted: s.Score})
+	}
+	return resp, nil
+}
@@ -1,214 +1,754 @@
-package main
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"log"
-	"net"
-	"net/http"
-	"os"
-	"time"
-
-	"pcd-pc4/internal/knn"
-	"pcd-pc4/pkg/database"
-	"pcd-pc4/pkg/network"
-)
-
-var (
-	userRatings map[string]map[string]float64
-	movieTitles map[string]string
-
-	// Nombres de contenedor Docker
-	nodes = []string{
-		"pcd-pc4_nodo1:9000",
-		"pcd-pc4_nodo2:9001",
-	}
-)
-
-const (
-	K    = 50
-	TopN = 10
-)
-
-func main() {
-	fmt.Println("Cargando datos limpios de MovieLens...")
-
-	userRatings = knn.LoadUserRatings("data/clean/ratings.csv")
-	movieTitles = knn.LoadMovieTitles("data/clean/movies.csv")
-
-	if len(userRatings) == 0 {
-		log.Fatal("No se pudieron cargar ratings.")
-	}
-
-	// --------------------------------------------------
-	// Conexión a MongoDB
-	// --------------------------------------------------
-
-	uri := os.Getenv("MONGO_URI")
-	if uri == "" {
-		uri = "mongodb://pcd-pc4_mongo:27017"
-	}
-
-	fmt.Println("Conectando a MongoDB en:", uri)
-
-	if err := database.Connect(uri); err != nil {
-		log.Fatal("Error conectando a MongoDB: ", err)
-	}
-
-	fmt.Println("Conexión a MongoDB lista.")
-
-	// --------------------------------------------------
-	// Iniciar servidor HTTP
-	// --------------------------------------------------
-
-	fmt.Println("API distribuida escuchando en puerto 8080...")
-
-	http.HandleFunc("/recommend/", handleRecommendUser)
-
-	log.Fatal(http.ListenAndServe(":8080", nil))
-}
-
-// -----------------------------------------------------------
-// ENDPOINT: GET /recommend/:userID
-// -----------------------------------------------------------
-
-func handleRecommendUser(w http.ResponseWriter, r *http.Request) {
-	user := r.URL.Path[len("/recommend/"):]
-	if user == "" {
-		http.Error(w, "Debe especificar un usuario", 400)
-		return
-	}
-
-	if _, ok := userRatings[user]; !ok {
-		http.Error(w, "Usuario no encontrado", 404)
-		return
-	}
-
-	start := time.Now()
-
-	recs, err := distributedRecommendation(user)
-	if err != nil {
-		http.Error(w, "Error en recomendación: "+err.Error(), 500)
-		return
-	}
-
-	latency := time.Since(start).Milliseconds()
-
-	// Guardar historial en MongoDB (asíncrono)
-	go saveRecommendationToMongo(user, recs, latency)
-
-	// Responder
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(recs)
-}
-
-// -----------------------------------------------------------
-// PROCESO DISTRIBUIDO: API → nodos ML
-// -----------------------------------------------------------
-
-func distributedRecommendation(targetUser string) ([]knn.Recommended, error) {
-	// Dividir usuarios en chunks (uno por nodo)
-	chunks := splitUsersIntoChunks(userRatings, len(nodes))
-
-	allNeighbors := []network.NeighborResult{}
-
-	for i, chunk := range chunks {
-		addr := nodes[i]
-
-		partial, err := sendTaskToNode(addr, targetUser, chunk)
-		if err != nil {
-			return nil, err
-		}
-
-		allNeighbors = append(allNeighbors, partial...)
-	}
-
-	// Selección global de top K vecinos
-	topK := knn.TopK(allNeighbors, K)
-
-	// Predecir ratings
-	recs := knn.PredictRatings(targetUser, userRatings, topK)
-
-	return knn.TopNRecommendations(recs, TopN), nil
-}
-
-// -----------------------------------------------------------
-// TCP: enviar tarea a cada nodo
-// -----------------------------------------------------------
-
-func sendTaskToNode(addr, target string, chunk map[string]map[string]float64) ([]network.NeighborResult, error) {
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		fmt.Println("Error conectando a nodo", addr, ":", err)
-		return nil, err
-	}
-	defer conn.Close()
-
-	req := network.TaskRequest{
-		TargetUser: target,
-		UserChunk:  chunk,
-		K:          K,
-	}
-
-	if err := network.Send(conn, req); err != nil {
-		return nil, err
-	}
-
-	var resp network.TaskResponse
-	if err := network.Receive(conn, &resp); err != nil {
-		return nil, err
-	}
-
-	return resp.PartialNeighbors, nil
-}
-
-// -----------------------------------------------------------
-// GUARDAR RECOMENDACIÓN EN MONGODB
-// -----------------------------------------------------------
-
-func saveRecommendationToMongo(user string, recs []knn.Recommended, latencyMS int64) {
-	col := database.RecsCollection()
-
-	// Convertimos recs (knn.Recommended) → RecommendedItem
-	items := make([]database.RecommendedItem, 0, len(recs))
-	for _, r := range recs {
-		items = append(items, database.RecommendedItem{
-			MovieID:   r.MovieID,
-			Predicted: r.Predicted,
-		})
-	}
-
-	doc := database.RecommendationDocument{
-		UserID:        user,
-		Recommended:   items,
-		LatencyMS:     latencyMS,
-		TimestampUnix: time.Now().Unix(),
-	}
-
-	_, err := col.InsertOne(context.Background(), doc)
-	if err != nil {
-		fmt.Println("Error guardando recomendación:", err)
-	}
-}
-
-// -----------------------------------------------------------
-// Dividir usuarios en N partes
-// -----------------------------------------------------------
-
-func splitUsersIntoChunks(data map[string]map[string]float64, parts int) []map[string]map[string]float64 {
-	chunks := make([]map[string]map[string]float64, parts)
-
-	for i := 0; i < parts; i++ {
-		chunks[i] = make(map[string]map[string]float64)
-	}
-
-	i := 0
-	for user, ratings := range data {
-		idx := i % parts
-		chunks[idx][user] = ratings
-		i++
-	}
-
-	return chunks
-}
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"pcd-pc4/internal/hybrid"
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/pkg/cluster"
+	"pcd-pc4/pkg/database"
+	"pcd-pc4/pkg/network"
+	"pcd-pc4/pkg/partition"
+)
+
+var (
+	userRatings map[string]map[string]float64
+	itemRatings map[string]map[string]float64 // película -> usuario -> rating
+	movieTitles map[string]string
+
+	// Medias por usuario y por película (coseno ajustado en los nodos)
+	userMeans map[string]float64
+	itemMeans map[string]float64
+)
+
+const (
+	K    = 50
+	TopN = 10
+
+	// Máximo de recomendaciones (?n=) y de vecinos (?k=) por petición
+	MaxN = 100
+	MaxK = 500
+)
+
+// Modelos seleccionables con /recommend/:userID?model=...
+const (
+	ModelUserKNN = "user"    // KNN user-based (por defecto)
+	ModelItemKNN = "item"    // KNN item-based
+	ModelMF      = "mf"      // factorización matricial (cmd/entrenar o POST /train/als)
+	ModelBPR     = "bpr"     // ranking BPR sobre interacciones implícitas
+	ModelContent = "content" // TF-IDF de géneros y tags
+	ModelHybrid  = "hybrid"  // KNN + contenido + popularidad
+)
+
+// Política ante shards que no responden a tiempo
+const (
+	PolicyDegrade = "degrade" // responder con los shards que sí contestaron
+	PolicyFail    = "fail"    // abortar la recomendación completa
+)
+
+var (
+	nodeDeadline      = 2 * time.Second        // plazo máximo por intento a un nodo
+	hedgeDelay        = 300 * time.Millisecond // tras este tiempo se consulta otra réplica
+	replicationFactor = 2                      // nodos que mantienen cada shard
+	partialPolicy     = PolicyDegrade
+
+	// Filtro de vecinos por defecto (MIN_OVERLAP, SIGNIFICANCE_N,
+	// SHRINKAGE); cada petición puede sobreescribirlo
+	defaultFilter knn.NeighborFilter
+
+	// Método de predicción por defecto (PREDICTION, MIN_SUPPORT)
+	defaultPredict = knn.PredictOptions{MinSupport: knn.DefaultMinSupport}
+)
+
+// Respuesta de /recommend/ (Degraded = faltó al menos un shard)
+type RecommendResponse struct {
+	RecommendationID string            `json:"recommendation_id,omitempty"` // para POST /feedback
+	UserID           string            `json:"user_id"`
+	Model            string            `json:"model"`
+	Similarity       string            `json:"similarity,omitempty"`
+	Recommendations  []knn.Recommended `json:"recommendations"`
+	Degraded         bool              `json:"degraded"`
+	MissingShards    []MissingShard    `json:"missing_shards,omitempty"`
+
+	// model=hybrid: estrategia y puntuación de cada componente
+	Strategy   string          `json:"strategy,omitempty"`
+	Components []hybrid.Scored `json:"components,omitempty"`
+
+	// Usuario sin historial (unknown) o con pocos ratings (sparse)
+	ColdStart string `json:"cold_start,omitempty"`
+
+	// Experimento A/B y brazo que atendió la petición
+	Experiment string `json:"experiment,omitempty"`
+	Arm        string `json:"arm,omitempty"`
+}
+
+type MissingShard struct {
+	Shard string   `json:"shard"`
+	Nodes []string `json:"nodes"`
+	Error string   `json:"error"`
+}
+
+func main() {
+	fmt.Println("Cargando datos limpios de MovieLens...")
+
+	userRatings = knn.LoadUserRatings("data/clean/ratings.csv")
+	movieTitles = knn.LoadMovieTitles("data/clean/movies.csv")
+
+	if len(userRatings) == 0 {
+		log.Fatal("No se pudieron cargar ratings.")
+	}
+
+	// --------------------------------------------------
+	// Conexión a MongoDB
+	// --------------------------------------------------
+
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		uri = "mongodb://pcd-pc4_mongo:27017"
+	}
+
+	fmt.Println("Conectando a MongoDB en:", uri)
+
+	if err := database.Connect(uri); err != nil {
+		log.Fatal("Error conectando a MongoDB: ", err)
+	}
+
+	fmt.Println("Conexión a MongoDB lista.")
+
+	if err := database.EnsureIndexes(); err != nil {
+		log.Fatal("Error creando índices en MongoDB: ", err)
+	}
+
+	// Ratings recibidos por POST /ratings desde que se generó el CSV
+	if err := replayRatings(); err != nil {
+		log.Fatal("Error cargando ratings de MongoDB: ", err)
+	}
+
+	itemRatings = knn.TransposeRatings(userRatings)
+	userMeans = knn.MeanRatings(userRatings)
+	itemMeans = knn.MeanRatings(itemRatings)
+
+	loadModels()
+	loadColdStart()
+	loadExperiment()
+
+	// --------------------------------------------------
+	// Plazos, réplicas, codecs, filtro de vecinos, predicción y
+	// política de resultados parciales
+	// --------------------------------------------------
+
+	if v := os.Getenv("NODE_DEADLINE_MS"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			log.Fatal("NODE_DEADLINE_MS inválido: ", v)
+		}
+		nodeDeadline = time.Duration(ms) * time.Millisecond
+	}
+
+	if v := os.Getenv("HEDGE_DELAY_MS"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			log.Fatal("HEDGE_DELAY_MS inválido: ", v)
+		}
+		// 0 desactiva las peticiones hedged
+		hedgeDelay = time.Duration(ms) * time.Millisecond
+	}
+
+	if v := os.Getenv("REPLICATION_FACTOR"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatal("REPLICATION_FACTOR inválido: ", v)
+		}
+		replicationFactor = n
+	}
+
+	codecs, err := network.ParseCodecs(os.Getenv("NODE_CODECS"))
+	if err != nil {
+		log.Fatal("NODE_CODECS inválido: ", err)
+	}
+	nodePool.Codecs = codecs
+
+	// Secreto compartido (firma del handshake y de la membresía) y TLS
+	// mutuo hacia los nodos, ambos opcionales
+	secret, err := network.SecretFromEnv("NODE")
+	if err != nil {
+		log.Fatal("NODE_SECRET inválido: ", err)
+	}
+	nodeAuth = network.NewAuthenticator(secret)
+	nodePool.Auth = nodeAuth
+
+	tlsCfg, err := network.TLSFromEnv("NODE", false)
+	if err != nil {
+		log.Fatal("Configuración TLS inválida: ", err)
+	}
+	if tlsCfg != nil {
+		dialer := &tls.Dialer{Config: tlsCfg}
+		nodePool.Dial = dialer.DialContext
+	}
+
+	fmt.Println("Tráfico a nodos: TLS", tlsCfg != nil, "HMAC", nodeAuth != nil)
+
+	if v := os.Getenv("MIN_OVERLAP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatal("MIN_OVERLAP inválido: ", v)
+		}
+		defaultFilter.MinOverlap = n
+	}
+
+	if v := os.Getenv("SIGNIFICANCE_N"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatal("SIGNIFICANCE_N inválido: ", v)
+		}
+		defaultFilter.Significance = n
+	}
+
+	if v := os.Getenv("SHRINKAGE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			log.Fatal("SHRINKAGE inválido: ", v)
+		}
+		defaultFilter.Shrinkage = f
+	}
+
+	defaultPredict.Method = os.Getenv("PREDICTION")
+	if err := knn.CheckPredictMethod(defaultPredict.Method); err != nil {
+		log.Fatal("PREDICTION inválido: ", err)
+	}
+
+	if v := os.Getenv("MIN_SUPPORT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatal("MIN_SUPPORT inválido: ", v)
+		}
+		defaultPredict.MinSupport = n
+	}
+
+	switch p := os.Getenv("PARTIAL_RESULTS"); p {
+	case "":
+	case PolicyDegrade, PolicyFail:
+		partialPolicy = p
+	default:
+		log.Fatal("PARTIAL_RESULTS debe ser degrade o fail: ", p)
+	}
+
+	// --------------------------------------------------
+	// Registro de nodos ML (join / heartbeat / leave)
+	// --------------------------------------------------
+
+	registry = cluster.NewRegistry(nodeTimeout, rebalance)
+	go registry.Sweep(nodeTimeout / 2)
+
+	// --------------------------------------------------
+	// Iniciar servidor HTTP
+	// --------------------------------------------------
+
+	fmt.Println("API distribuida escuchando en puerto 8080...")
+
+	http.HandleFunc("/recommend/", handleRecommendUser)
+	http.HandleFunc("/nodes", handleListNodes)
+	http.HandleFunc("/nodes/join", handleNodeJoin)
+	http.HandleFunc("/nodes/heartbeat", handleNodeHeartbeat)
+	http.HandleFunc("/nodes/leave", handleNodeLeave)
+	http.HandleFunc("/train/als", handleTrainALS)
+	http.HandleFunc("/experiments", handleExperiment)
+	http.HandleFunc("/experiments/report", handleExperimentReport)
+	http.HandleFunc("/feedback", handleFeedback)
+	http.HandleFunc("/feedback/report", handleFeedbackReport)
+	http.HandleFunc("/ratings", handleRatings)
+
+	log.Fatal(http.ListenAndServe(":8080", nil))
+}
+
+// -----------------------------------------------------------
+// ENDPOINT: GET /recommend/:userID[?model=user|item|mf|bpr|content|hybrid
+//                                   &n=&k=&similarity=&min_overlap=&significance=
+//                                   &shrinkage=&prediction=&min_support=
+//                                   &strategy=&weights=&w_knn=&w_content=&w_popularity=
+//                                   &genres=Comedy,Drama]
+// -----------------------------------------------------------
+
+// Parámetros de una recomendación (query string de /recommend/)
+type recommendOptions struct {
+	model      string
+	n          int
+	k          int // vecinos del KNN
+	similarity string
+	filter     knn.NeighborFilter
+	predict    knn.PredictOptions // sólo user-based
+	strategy   string             // sólo hybrid
+	weights    hybrid.Weights     // sólo hybrid
+	genres     []string           // filtro de populares en cold start
+
+	experiment string // experimento A/B y brazo asignado ("" = fuera)
+	arm        string
+}
+
+func parseRecommendOptions(user string, r *http.Request) (recommendOptions, error) {
+	q := r.URL.Query()
+	opts := recommendOptions{
+		model:      q.Get("model"),
+		n:          TopN,
+		k:          K,
+		similarity: q.Get("similarity"),
+		genres:     parseGenres(q.Get("genres")),
+		filter:     defaultFilter,
+		predict:    defaultPredict,
+	}
+
+	// El brazo del experimento fija modelo, similitud y K salvo que la
+	// petición los pida explícitamente
+	applyExperiment(user, q, &opts)
+
+	switch opts.model {
+	case "":
+		opts.model = ModelUserKNN
+	case ModelUserKNN, ModelItemKNN, ModelMF, ModelBPR, ModelContent, ModelHybrid:
+	default:
+		return opts, fmt.Errorf("modelo desconocido: %s", opts.model)
+	}
+
+	if v := q.Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxN {
+			return opts, fmt.Errorf("n debe estar entre 1 y %d: %s", MaxN, v)
+		}
+		opts.n = n
+	}
+
+	if v := q.Get("k"); v != "" {
+		k, err := strconv.Atoi(v)
+		if err != nil || k < 1 || k > MaxK {
+			return opts, fmt.Errorf("k debe estar entre 1 y %d: %s", MaxK, v)
+		}
+		opts.k = k
+	}
+
+	if opts.similarity == "" {
+		opts.similarity = knn.SimCosine
+	}
+	if _, err := knn.NewSimilarity(opts.similarity, nil); err != nil {
+		return opts, err
+	}
+
+	if v := q.Get("min_overlap"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("min_overlap inválido: %s", v)
+		}
+		opts.filter.MinOverlap = n
+	}
+
+	if v := q.Get("significance"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("significance inválido: %s", v)
+		}
+		opts.filter.Significance = n
+	}
+
+	if v := q.Get("shrinkage"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return opts, fmt.Errorf("shrinkage inválido: %s", v)
+		}
+		opts.filter.Shrinkage = f
+	}
+
+	if v := q.Get("prediction"); v != "" {
+		if err := knn.CheckPredictMethod(v); err != nil {
+			return opts, err
+		}
+		opts.predict.Method = v
+	}
+
+	if v := q.Get("min_support"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("min_support inválido: %s", v)
+		}
+		opts.predict.MinSupport = n
+	}
+
+	if err := parseHybridOptions(q, &opts); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+func handleRecommendUser(w http.ResponseWriter, r *http.Request) {
+	user := r.URL.Path[len("/recommend/"):]
+	if user == "" {
+		http.Error(w, "Debe especificar un usuario", 400)
+		return
+	}
+
+	opts, err := parseRecommendOptions(user, r)
+	if err != nil {
+		http.Error(w, "Parámetros inválidos: "+err.Error(), 400)
+		return
+	}
+
+	start := time.Now()
+
+	resp, err := recommend(r.Context(), user, opts)
+	if errors.Is(err, errUserNotInModel) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if errors.Is(err, errModelUnavailable) {
+		http.Error(w, err.Error(), 503)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error en recomendación: "+err.Error(), 500)
+		return
+	}
+
+	latency := time.Since(start).Milliseconds()
+	resp.Experiment, resp.Arm = opts.experiment, opts.arm
+
+	// Guardar historial en MongoDB antes de responder: el cliente envía
+	// feedback con el recommendation_id apenas muestra la lista. Si no se
+	// pudo guardar se responde igual, sin id.
+	id := primitive.NewObjectID()
+	if err := saveRecommendationToMongo(r.Context(), id, user, opts, resp, latency); err != nil {
+		fmt.Println("Error guardando recomendación:", err)
+	} else {
+		resp.RecommendationID = id.Hex()
+	}
+
+	// Responder
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// recommend delega en el modelo pedido: KNN en los nodos o un modelo
+// entrenado que el API sirve localmente. Los usuarios sin historial
+// reciben populares y los que tienen pocos ratings, una mezcla.
+func recommend(ctx context.Context, user string, opts recommendOptions) (RecommendResponse, error) {
+	// Con model=bpr también cuentan las interacciones implícitas
+	history := len(historyOf(user, opts.model))
+	if history == 0 {
+		return recommendPopular(user, opts), nil
+	}
+
+	resp, err := recommendPersonalized(ctx, user, opts)
+
+	if coldStartMinRatings > 0 && history < coldStartMinRatings {
+		if err != nil {
+			// Muy poca información para el modelo: sólo populares
+			fmt.Println("Cold start para", user, "sin modelo personalizado:", err)
+			resp = RecommendResponse{UserID: user, Model: opts.model}
+		}
+		return blendWithPopular(resp, user, opts)
+	}
+	return resp, err
+}
+
+func recommendPersonalized(ctx context.Context, user string, opts recommendOptions) (RecommendResponse, error) {
+	switch opts.model {
+	case ModelMF:
+		return recommendMF(user, opts.n)
+	case ModelBPR:
+		return recommendBPR(user, opts.n)
+	case ModelContent:
+		return recommendContent(user, opts.n)
+	case ModelHybrid:
+		return recommendHybrid(ctx, user, opts)
+	default:
+		return distributedRecommendation(ctx, user, opts)
+	}
+}
+
+// -----------------------------------------------------------
+// PROCESO DISTRIBUIDO: API → nodos ML (scatter-gather)
+// -----------------------------------------------------------
+
+type shardResult struct {
+	shard   shardPlacement
+	partial network.TaskResponse
+	err     error
+}
+
+func distributedRecommendation(ctx context.Context, targetUser string, opts recommendOptions) (RecommendResponse, error) {
+	resp := RecommendResponse{UserID: targetUser, Model: opts.model, Similarity: opts.similarity}
+	targetRatings := ratingsOf(targetUser)
+
+	task := network.TaskRequest{
+		TargetUser:    targetUser,
+		TargetRatings: targetRatings,
+		K:             opts.k,
+		Mode:          network.ModeUser,
+		Similarity:    opts.similarity,
+		MinOverlap:    opts.filter.MinOverlap,
+		Significance:  opts.filter.Significance,
+		Shrinkage:     opts.filter.Shrinkage,
+	}
+
+	// Item-based: cada nodo compara sus películas con las que el
+	// usuario calificó. La tarea lleva sólo la versión de cada vector;
+	// queryNode envía los vectores que el nodo no tenga (RatedItems queda
+	// aquí como reserva y no viaja en la primera petición).
+	if opts.model == ModelItemKNN {
+		task.Mode = network.ModeItem
+		task.N = opts.n
+		task.RatedItems, task.ItemVersions = ratedItems(targetRatings)
+	}
+
+	shards := currentAssignment().ofKind(task.Mode)
+	if len(shards) == 0 {
+		return resp, fmt.Errorf("no hay nodos ML disponibles")
+	}
+
+	// Consultar todos los shards en paralelo (cada uno con failover)
+	results := make(chan shardResult, len(shards))
+
+	for _, sh := range shards {
+		go func(sh shardPlacement) {
+			partial, err := queryShard(ctx, sh, task)
+			results <- shardResult{shard: sh, partial: partial, err: err}
+		}(sh)
+	}
+
+	allNeighbors := []network.NeighborResult{}
+	allScores := []knn.Recommended{}
+
+	for range shards {
+		res := <-results
+		if res.err != nil {
+			fmt.Println("Shard", res.shard.id, "sin respuesta:", res.err)
+			resp.MissingShards = append(resp.MissingShards, MissingShard{
+				Shard: res.shard.id,
+				Nodes: res.shard.replicas,
+				Error: res.err.Error(),
+			})
+			continue
+		}
+
+		allNeighbors = append(allNeighbors, res.partial.PartialNeighbors...)
+		for _, p := range res.partial.Predictions {
+			allScores = append(allScores, knn.Recommended{MovieID: p.MovieID, Predicted: p.Predicted})
+		}
+	}
+
+	if len(resp.MissingShards) > 0 {
+		if partialPolicy == PolicyFail || len(resp.MissingShards) == len(shards) {
+			return resp, fmt.Errorf("%d de %d shards sin respuesta", len(resp.MissingShards), len(shards))
+		}
+		resp.Degraded = true
+	}
+
+	// Item-based: los nodos ya predijeron; sólo queda el top N global
+	if opts.model == ModelItemKNN {
+		resp.Recommendations = knn.TopNRecommendations(allScores, opts.n)
+		return resp, nil
+	}
+
+	// Selección global de top K vecinos
+	topK := knn.TopK(allNeighbors, opts.k)
+
+	// Predecir ratings
+	ratingsMu.RLock()
+	recs := knn.PredictRatingsWith(targetUser, userRatings, topK, opts.predict)
+	ratingsMu.RUnlock()
+
+	resp.Recommendations = knn.TopNRecommendations(recs, opts.n)
+	return resp, nil
+}
+
+// queryShard consulta la réplica preferida del shard. Si falla o vence su
+// plazo se pasa a la siguiente réplica, y si tarda más de hedgeDelay se
+// lanza en paralelo una petición hedged a la segunda; gana la primera
+// respuesta válida y el resto se cancela.
+func queryShard(ctx context.Context, sh shardPlacement, task network.TaskRequest) (network.TaskResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type attempt struct {
+		addr    string
+		partial network.TaskResponse
+		err     error
+	}
+
+	attempts := make(chan attempt, len(sh.replicas))
+	next, inflight := 0, 0
+
+	launch := func() {
+		addr := sh.replicas[next]
+		next++
+		inflight++
+
+		go func() {
+			nodeCtx, cancelNode := context.WithTimeout(ctx, nodeDeadline)
+			defer cancelNode()
+
+			partial, err := queryNode(nodeCtx, addr, sh, task)
+			attempts <- attempt{addr: addr, partial: partial, err: err}
+		}()
+	}
+
+	launch()
+
+	var hedge <-chan time.Time
+	if hedgeDelay > 0 && len(sh.replicas) > 1 {
+		timer := time.NewTimer(hedgeDelay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	var errs []string
+
+	for inflight > 0 {
+		select {
+		case res := <-attempts:
+			inflight--
+			if res.err == nil {
+				return res.partial, nil
+			}
+
+			errs = append(errs, res.addr+": "+res.err.Error())
+
+			// Un usuario desconocido lo es en todas las réplicas
+			var remote *network.RemoteError
+			if errors.As(res.err, &remote) && remote.Code == network.ErrCodeUnknownUser {
+				return network.TaskResponse{}, res.err
+			}
+
+			// Failover a la siguiente réplica (caída, timeout, overloaded...)
+			if next < len(sh.replicas) {
+				launch()
+			}
+
+		case <-hedge:
+			hedge = nil
+			if next < len(sh.replicas) {
+				launch()
+			}
+		}
+	}
+
+	return network.TaskResponse{}, errors.New(strings.Join(errs, "; "))
+}
+
+// queryNode consulta un nodo; si éste perdió el shard (p. ej. reinicio) se
+// lo recarga en segundo plano y se devuelve el error para usar otra réplica.
+// En item-based la tarea viaja sin vectores y, si el nodo pide alguno, se
+// repite sólo con ésos.
+func queryNode(ctx context.Context, addr string, sh shardPlacement, task network.TaskRequest) (network.TaskResponse, error) {
+	vectors := task.RatedItems
+	if task.ItemVersions != nil {
+		task.RatedItems = nil
+	}
+
+	resp, err := sendTaskToNode(ctx, addr, sh, task)
+	if err == nil && len(resp.MissingItems) > 0 {
+		task.RatedItems = make(map[string]map[string]float64, len(resp.MissingItems))
+		for _, movie := range resp.MissingItems {
+			task.RatedItems[movie] = vectors[movie]
+		}
+		resp, err = sendTaskToNode(ctx, addr, sh, task)
+	}
+	if err == nil && len(resp.MissingItems) > 0 {
+		err = fmt.Errorf("nodo %s sigue sin %d vectores", addr, len(resp.MissingItems))
+	}
+
+	var remote *network.RemoteError
+	if errors.As(err, &remote) && remote.Code == network.ErrCodeShardNotLoaded {
+		go reloadShard(addr, sh)
+	}
+	return resp, err
+}
+
+// -----------------------------------------------------------
+// TCP: enviar tarea a cada nodo (conexión persistente del pool)
+// -----------------------------------------------------------
+
+func sendTaskToNode(ctx context.Context, addr string, sh shardPlacement, task network.TaskRequest) (network.TaskResponse, error) {
+	task.Shard = sh.id
+	task.Version = sh.version
+
+	msg := network.Envelope{
+		Type: network.MsgTask,
+		Task: &task,
+	}
+
+	reply, err := nodePool.Call(ctx, addr, msg)
+	if err != nil {
+		fmt.Println("Error consultando nodo", addr, ":", err)
+		return network.TaskResponse{}, err
+	}
+	if reply.TaskResult == nil {
+		return network.TaskResponse{}, fmt.Errorf("nodo %s respondió %q sin resultado", addr, reply.Type)
+	}
+
+	return *reply.TaskResult, nil
+}
+
+// -----------------------------------------------------------
+// GUARDAR RECOMENDACIÓN EN MONGODB
+// -----------------------------------------------------------
+
+func saveRecommendationToMongo(ctx context.Context, id primitive.ObjectID, user string, opts recommendOptions, resp RecommendResponse, latencyMS int64) error {
+	col := database.RecsCollection()
+
+	// Convertimos recs (knn.Recommended) → RecommendedItem
+	items := make([]database.RecommendedItem, 0, len(resp.Recommendations))
+	for _, r := range resp.Recommendations {
+		items = append(items, database.RecommendedItem{
+			MovieID:   r.MovieID,
+			Predicted: r.Predicted,
+		})
+	}
+
+	doc := database.RecommendationDocument{
+		ID:            id,
+		UserID:        user,
+		Model:         opts.model,
+		ColdStart:     resp.ColdStart,
+		Experiment:    opts.experiment,
+		Arm:           opts.arm,
+		Recommended:   items,
+		LatencyMS:     latencyMS,
+		TimestampUnix: time.Now().Unix(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	_, err := col.InsertOne(ctx, doc)
+	return err
+}
+
+// -----------------------------------------------------------
+// Repartir usuarios (o películas) según su dueño en el anillo
+// -----------------------------------------------------------
+
+func splitByRing(data map[string]map[string]float64, ring *partition.Ring) map[string]map[string]map[string]float64 {
+	shards := make(map[string]map[string]map[string]float64)
+
+	for _, node := range ring.Nodes() {
+		shards[node] = make(map[string]map[string]float64)
+	}
+
+	for key, vector := range data {
+		owner := ring.Owner(key)
+		shards[owner][key] = vector
+	}
+
+	return shards
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"pcd-pc4/internal/bpr"
+	"pcd-pc4/internal/content"
+	"pcd-pc4/internal/mf"
+	"pcd-pc4/internal/popularity"
+)
+
+// -----------------------------------------------------------
+// Modelos entrenados offline que el API sirve localmente
+// -----------------------------------------------------------
+
+var (
+	errModelUnavailable = errors.New("modelo no disponible")
+	errUserNotInModel   = errors.New("usuario no presente en el modelo")
+)
+
+var (
+	// Modelo MF vigente; lo reemplaza un entrenamiento ALS terminado
+	mfMu        sync.RWMutex
+	mfModel     *mf.Model
+	mfModelPath string
+
+	bprModel *bpr.Model
+
+	// Interacciones implícitas (vistas, clics) por usuario; se excluyen
+	// de las recomendaciones BPR junto con lo ya calificado
+	userInteractions bpr.Interactions
+
+	// Perfiles TF-IDF de películas y popularidad; se arman al arrancar
+	contentModel    *content.Model
+	popularityModel *popularity.Model
+)
+
+func currentMF() *mf.Model {
+	mfMu.RLock()
+	defer mfMu.RUnlock()
+
+	return mfModel
+}
+
+// setMF publica un modelo nuevo y lo guarda para los próximos arranques
+func setMF(m *mf.Model) error {
+	mfMu.Lock()
+	mfModel = m
+	mfMu.Unlock()
+
+	return m.Save(mfModelPath)
+}
+
+// loadModels carga los modelos entrenados; si falta alguno el API arranca
+// igual y sólo ese modelo responde 503.
+func loadModels() {
+	mfModelPath = os.Getenv("MF_MODEL_PATH")
+	if mfModelPath == "" {
+		mfModelPath = "models/mf.gob"
+	}
+
+	if m, err := mf.Load(mfModelPath); err != nil {
+		fmt.Println("Modelo MF no cargado (model=mf desactivado):", err)
+	} else {
+		mfMu.Lock()
+		mfModel = m
+		mfMu.Unlock()
+
+		fmt.Println("Modelo MF cargado:", m.Factors, "factores,", len(m.Users), "usuarios,", len(m.Items), "películas")
+	}
+
+	bprPath := os.Getenv("BPR_MODEL_PATH")
+	if bprPath == "" {
+		bprPath = "models/bpr.gob"
+	}
+
+	if m, err := bpr.Load(bprPath); err != nil {
+		fmt.Println("Modelo BPR no cargado (model=bpr desactivado):", err)
+	} else {
+		bprModel = m
+		fmt.Println("Modelo BPR cargado:", m.Factors, "factores,", len(m.Users), "usuarios,", len(m.Items), "películas")
+	}
+
+	interactionsPath := os.Getenv("INTERACTIONS_PATH")
+	if interactionsPath == "" {
+		interactionsPath = "data/clean/interactions.csv"
+	}
+
+	in, err := bpr.LoadInteractions(interactionsPath)
+	if err != nil {
+		fmt.Println("Sin interacciones implícitas:", err)
+		in = bpr.Interactions{}
+	}
+	userInteractions = in
+
+	terms := content.LoadMovieTerms("data/clean/movies.csv")
+	content.AddTags(terms, "data/clean/tags.csv")
+	contentModel = content.Build(terms)
+
+	fmt.Println("Modelo de contenido listo:", len(contentModel.Vectors), "películas,", len(contentModel.IDF), "términos")
+
+	minVotes := 10.0
+	if v := os.Getenv("POPULARITY_MIN_VOTES"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			log.Fatal("POPULARITY_MIN_VOTES inválido: ", v)
+		}
+		minVotes = f
+	}
+	popularityModel = popularity.Build(itemRatings, minVotes)
+
+	loadHybridWeights()
+}
+
+func recommendMF(user string, n int) (RecommendResponse, error) {
+	resp := RecommendResponse{UserID: user, Model: ModelMF}
+	model := currentMF()
+	if model == nil {
+		return resp, fmt.Errorf("%w: mf", errModelUnavailable)
+	}
+
+	if _, ok := model.Users[user]; !ok {
+		return resp, fmt.Errorf("%w: %s (mf)", errUserNotInModel, user)
+	}
+
+	recs, err := model.Recommend(user, ratingsOf(user), n)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Recommendations = recs
+	return resp, nil
+}
+
+// historyOf: películas que cuentan como historial del usuario para el
+// modelo (excluidas de sus recomendaciones). Con model=bpr también las
+// interacciones implícitas (vistas, clics) además de los ratings.
+func historyOf(user, model string) map[string]float64 {
+	ratings := ratingsOf(user)
+	if model != ModelBPR || len(userInteractions[user]) == 0 {
+		return ratings
+	}
+
+	seen := make(map[string]float64, len(ratings)+len(userInteractions[user]))
+	for movie, r := range ratings {
+		seen[movie] = r
+	}
+	for movie, n := range userInteractions[user] {
+		seen[movie] = n
+	}
+	return seen
+}
+
+// recommendBPR ordena por puntuación de ranking (Predicted no es un rating)
+func recommendBPR(user string, n int) (RecommendResponse, error) {
+	resp := RecommendResponse{UserID: user, Model: ModelBPR}
+	if bprModel == nil {
+		return resp, fmt.Errorf("%w: bpr", errModelUnavailable)
+	}
+	if _, ok := bprModel.Users[user]; !ok {
+		return resp, fmt.Errorf("%w: %s (bpr)", errUserNotInModel, user)
+	}
+
+	recs, err := bprModel.Recommend(user, historyOf(user, ModelBPR), n)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Recommendations = recs
+	return resp, nil
+}
+
+// recommendContent: similitud entre el perfil del usuario y cada película
+// (Predicted es un coseno, no un rating); basta con un rating.
+func recommendContent(user string, n int) (RecommendResponse, error) {
+	resp := RecommendResponse{UserID: user, Model: ModelContent}
+	if len(contentModel.Vectors) == 0 {
+		return resp, fmt.Errorf("%w: content", errModelUnavailable)
+	}
+
+	ratings := ratingsOf(user)
+	resp.Recommendations = contentModel.Recommend(contentModel.UserProfile(ratings), ratings, n)
+	return resp, nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"pcd-pc4/pkg/cluster"
+	"pcd-pc4/pkg/network"
+	"pcd-pc4/pkg/partition"
+)
+
+const (
+	// Un nodo se descarta si no envía heartbeat en este intervalo
+	nodeTimeout = 6 * time.Second
+
+	// Plazo para transferir un shard completo a un nodo
+	loadTimeout = 30 * time.Second
+
+	// Puntos por nodo en el anillo de consistent hashing
+	virtualNodes = 128
+)
+
+var (
+	registry *cluster.Registry
+
+	// Conexiones persistentes y multiplexadas hacia los nodos
+	nodePool = network.NewPool()
+
+	// Firma HMAC compartida con los nodos (nil = desactivada)
+	nodeAuth *network.Authenticator
+)
+
+// -----------------------------------------------------------
+// Asignación vigente de shards con réplicas
+// -----------------------------------------------------------
+
+// shardPlacement: los usuarios (o, en shards item-based, las películas)
+// cuyo dueño en el anillo es un nodo y los nodos que lo mantienen. El id
+// es "<kind>@<dirección del dueño>". La primera réplica es la preferida;
+// el resto se usa para failover/hedging.
+type shardPlacement struct {
+	id       string
+	kind     string
+	version  int64
+	users    map[string]map[string]float64
+	means    map[string]float64 // medias de la otra dimensión (coseno ajustado)
+	replicas []string
+}
+
+type shardAssignment struct {
+	shards []shardPlacement
+	ring   *partition.Ring // dueño de cada usuario/película (ratings nuevos)
+	gen    uint64          // crece con cada asignación calculada
+}
+
+var (
+	assignMu   sync.RWMutex
+	assignment shardAssignment
+
+	// Última asignación calculada; va por delante de assignment mientras
+	// las réplicas confirman un update de ratings
+	latestAssignment shardAssignment
+
+	// Serializa los rebalanceos cuando cambian varios nodos a la vez y
+	// el cálculo de cada asignación nueva
+	rebalanceMu sync.Mutex
+
+	// Shards con una recarga en curso (clave nodo/shard)
+	reloading sync.Map
+)
+
+func currentAssignment() shardAssignment {
+	assignMu.RLock()
+	defer assignMu.RUnlock()
+
+	return assignment
+}
+
+// publishAssignment deja vigente next salvo que ya se haya publicado una
+// más nueva (dos lotes de ratings pueden confirmar en otro orden)
+func publishAssignment(next shardAssignment) {
+	assignMu.Lock()
+	defer assignMu.Unlock()
+
+	if next.gen > assignment.gen {
+		assignment = next
+	}
+}
+
+// ofKind devuelve los shards de un tipo (network.ModeUser o ModeItem)
+func (a shardAssignment) ofKind(kind string) []shardPlacement {
+	var list []shardPlacement
+	for _, sh := range a.shards {
+		if sh.kind == kind {
+			list = append(list, sh)
+		}
+	}
+	return list
+}
+
+// rebalance reparte los usuarios entre los nodos vivos con consistent
+// hashing: hay un shard de usuarios y uno de películas (item-based) por
+// nodo, y cada shard se copia en los
+// replicationFactor nodos que le siguen en el anillo. Al agregar un nodo
+// sólo cambia de dueño ~1/N de los usuarios, y los nodos conservan los
+// shards cuya versión no cambió (p. ej. tras reiniciar el API).
+func rebalance(alive []string) {
+	go func() {
+		rebalanceMu.Lock()
+		defer rebalanceMu.Unlock()
+
+		// Usar siempre la membresía más reciente
+		alive = registry.Alive()
+
+		fmt.Println("Membresía cambió, nodos vivos:", alive)
+
+		ring := partition.NewRing(alive, virtualNodes)
+
+		assignMu.RLock()
+		gen := latestAssignment.gen + 1
+		assignMu.RUnlock()
+
+		ratingsMu.RLock()
+		usersByOwner := splitByRing(userRatings, ring)
+		itemsByOwner := splitByRing(itemRatings, ring)
+		uMeans, iMeans := userMeans, itemMeans
+		ratingsMu.RUnlock()
+		next := shardAssignment{ring: ring, gen: gen}
+
+		for _, owner := range ring.Nodes() {
+			replicas := ring.Successors(owner, replicationFactor)
+
+			for _, kind := range []string{network.ModeUser, network.ModeItem} {
+				vectors, means := usersByOwner[owner], iMeans
+				if kind == network.ModeItem {
+					vectors, means = itemsByOwner[owner], uMeans
+				}
+
+				next.shards = append(next.shards, shardPlacement{
+					id:       kind + "@" + owner,
+					kind:     kind,
+					version:  shardVersion(vectors, means),
+					users:    vectors,
+					means:    means,
+					replicas: replicas,
+				})
+			}
+		}
+
+		// Shards que debe mantener cada nodo
+		held := make(map[string][]shardPlacement)
+		for _, sh := range next.shards {
+			for _, addr := range sh.replicas {
+				held[addr] = append(held[addr], sh)
+			}
+		}
+
+		var wg sync.WaitGroup
+		for addr, list := range held {
+			wg.Add(1)
+			go func(addr string, list []shardPlacement) {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+				defer cancel()
+
+				if err := syncNode(ctx, addr, list); err != nil {
+					// El nodo recibirá sus shards en la primera consulta
+					fmt.Println("No se pudo sincronizar", addr, ":", err)
+				}
+			}(addr, list)
+		}
+		wg.Wait()
+
+		assignMu.Lock()
+		previous := assignment
+		assignment, latestAssignment = next, next
+		assignMu.Unlock()
+
+		// Cerrar las conexiones de los nodos que salieron
+		for _, sh := range previous.shards {
+			for _, addr := range sh.replicas {
+				if _, ok := held[addr]; !ok {
+					nodePool.Drop(addr)
+				}
+			}
+		}
+	}()
+}
+
+// syncNode envía al nodo su asignación y carga sólo los shards que le faltan
+func syncNode(ctx context.Context, addr string, list []shardPlacement) error {
+	want := make(map[string]int64, len(list))
+	byID := make(map[string]shardPlacement, len(list))
+	for _, sh := range list {
+		want[sh.id] = sh.version
+		byID[sh.id] = sh
+	}
+
+	msg := network.Envelope{
+		Type:   network.MsgAssign,
+		Assign: &network.AssignRequest{Shards: want},
+	}
+
+	reply, err := nodePool.Call(ctx, addr, msg)
+	if err != nil {
+		return err
+	}
+	if reply.AssignResult == nil {
+		return fmt.Errorf("nodo %s respondió %q sin asignación", addr, reply.Type)
+	}
+
+	for _, id := range reply.AssignResult.Missing {
+		if err := loadShardOnNode(ctx, addr, byID[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shardVersion identifica el contenido de un shard (usuarios, ratings y
+// medias); es estable entre reinicios del API. Como es un XOR, al cambiar
+// un vector o una media se actualiza sin recorrer el shard (ver
+// nextAssignment).
+func shardVersion(users map[string]map[string]float64, means map[string]float64) int64 {
+	var v uint64
+	for id, vec := range users {
+		v ^= vectorHash(id, vec)
+	}
+	for id, m := range means {
+		v ^= meanHash(id, m)
+	}
+	return int64(v)
+}
+
+// vectorHash no depende del orden de recorrido del mapa
+func vectorHash(id string, vec map[string]float64) uint64 {
+	var sum uint64
+	for movie, r := range vec {
+		sum += partition.Hash(movie + ":" + strconv.FormatFloat(r, 'g', -1, 64))
+	}
+	return partition.Hash(id + "#" + strconv.FormatUint(sum, 16))
+}
+
+func meanHash(id string, m float64) uint64 {
+	return partition.Hash("media|" + id + ":" + strconv.FormatFloat(m, 'g', -1, 64))
+}
+
+// reloadShard vuelve a enviar un shard a un nodo que lo perdió, evitando
+// recargas simultáneas del mismo shard en el mismo nodo.
+func reloadShard(addr string, sh shardPlacement) {
+	key := addr + "/" + sh.id
+	if _, busy := reloading.LoadOrStore(key, true); busy {
+		return
+	}
+	defer reloading.Delete(key)
+
+	fmt.Println("Nodo", addr, "sin shard", sh.id, "recargando...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+
+	if err := loadShardOnNode(ctx, addr, sh); err != nil {
+		fmt.Println("No se pudo recargar shard", sh.id, "en", addr, ":", err)
+	}
+}
+
+func loadShardOnNode(ctx context.Context, addr string, sh shardPlacement) error {
+	msg := network.Envelope{
+		Type: network.MsgLoadShard,
+		Load: &network.LoadShardRequest{
+			Shard:   sh.id,
+			Version: sh.version,
+			Users:   sh.users,
+			Kind:    sh.kind,
+			Means:   sh.means,
+		},
+	}
+
+	reply, err := nodePool.Call(ctx, addr, msg)
+	if err != nil {
+		return err
+	}
+	if reply.LoadResult == nil {
+		return fmt.Errorf("nodo %s respondió %q sin confirmar la carga", addr, reply.Type)
+	}
+
+	fmt.Println("Shard", sh.id, "cargado en", addr, "con", reply.LoadResult.Users, "vectores")
+	return nil
+}
+
+// -----------------------------------------------------------
+// ENDPOINTS: membresía de nodos
+// -----------------------------------------------------------
+
+func handleListNodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(registry.Alive())
+}
+
+func handleNodeJoin(w http.ResponseWriter, r *http.Request) {
+	handleMembership(w, r, "join", registry.Join)
+}
+
+func handleNodeHeartbeat(w http.ResponseWriter, r *http.Request) {
+	handleMembership(w, r, "heartbeat", registry.Heartbeat)
+}
+
+func handleNodeLeave(w http.ResponseWriter, r *http.Request) {
+	handleMembership(w, r, "leave", registry.Leave)
+}
+
+func handleMembership(w http.ResponseWriter, r *http.Request, action string, apply func(addr string)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", 405)
+		return
+	}
+
+	var info cluster.NodeInfo
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil || info.Addr == "" {
+		http.Error(w, "Cuerpo inválido", 400)
+		return
+	}
+
+	signature := r.Header.Get(cluster.SignatureHeader)
+	if err := nodeAuth.Verify(cluster.SignedPayload(action, info.Addr), signature); err != nil {
+		http.Error(w, "No autorizado: "+err.Error(), 401)
+		return
+	}
+
+	apply(info.Addr)
+	w.WriteHeader(http.StatusOK)
+}
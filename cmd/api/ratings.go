@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/pkg/database"
+	"pcd-pc4/pkg/network"
+)
+
+// -----------------------------------------------------------
+// Ratings en línea: POST /ratings los guarda en MongoDB,
+// actualiza la matriz en memoria y envía los vectores y medias
+// nuevos a los nodos que mantienen esos shards, sin reiniciar el
+// API. Los usan al instante KNN (user e item-based), MF (fold-in)
+// y los perfiles de contenido, que se arman con los ratings del
+// usuario en cada consulta; los puntajes de popularidad, los
+// vectores TF-IDF y BPR siguen siendo los del arranque o del
+// último entrenamiento hasta reiniciar el API.
+// -----------------------------------------------------------
+
+const (
+	// Ratings máximos por POST /ratings
+	ratingsMaxBatch = 1000
+
+	// Plazo para que una réplica aplique los vectores nuevos; si no lo
+	// logra se le recarga el shard completo
+	updateTimeout = 5 * time.Second
+
+	// Regularización al incorporar usuarios al modelo MF (la de POST
+	// /train/als por defecto)
+	foldInReg = 0.1
+)
+
+// Los vectores de userRatings/itemRatings y los mapas de medias
+// (userMeans/itemMeans) no se modifican: un rating nuevo los reemplaza
+// por copias bajo ratingsMu, así que lo ya obtenido se puede leer sin
+// lock.
+var ratingsMu sync.RWMutex
+
+// Versión del vector de cada película para la caché de los nodos
+// (item-based): cambia cuando la película recibe ratings. Parte del
+// instante de arranque para que tras un reinicio los nodos no usen
+// vectores de la ejecución anterior.
+var (
+	itemEpoch    = time.Now().UnixNano()
+	itemVersions = map[string]int64{}
+	ratingsSeq   int64 // lotes aplicados desde el arranque
+)
+
+func ratingsOf(user string) map[string]float64 {
+	ratingsMu.RLock()
+	defer ratingsMu.RUnlock()
+
+	return userRatings[user]
+}
+
+// ratedItems devuelve los vectores (usuario -> rating) de las películas
+// de ratings y la versión de cada uno, leídos a la vez
+func ratedItems(ratings map[string]float64) (map[string]map[string]float64, map[string]int64) {
+	ratingsMu.RLock()
+	defer ratingsMu.RUnlock()
+
+	vectors := make(map[string]map[string]float64, len(ratings))
+	versions := make(map[string]int64, len(ratings))
+	for movie := range ratings {
+		vectors[movie] = itemRatings[movie]
+		versions[movie] = itemEpoch
+		if v, ok := itemVersions[movie]; ok {
+			versions[movie] = v
+		}
+	}
+	return vectors, versions
+}
+
+type RatingInput struct {
+	UserID    string  `json:"user_id"`
+	MovieID   string  `json:"movie_id"`
+	Rating    float64 `json:"rating"`
+	Timestamp int64   `json:"timestamp,omitempty"` // unix (0 = ahora)
+}
+
+// RatingsRequest acepta un rating suelto (user_id, movie_id, rating) o
+// una lista en ratings
+type RatingsRequest struct {
+	RatingInput
+	Ratings []RatingInput `json:"ratings,omitempty"`
+}
+
+type RatingsResponse struct {
+	Recorded int `json:"recorded"`
+	Users    int `json:"users"`  // usuarios con vector actualizado
+	Movies   int `json:"movies"` // películas con vector actualizado
+
+	// Réplicas que aplicaron los vectores nuevos y réplicas a las que se
+	// les recarga el shard completo (mientras tanto las consultas de ese
+	// shard van a las otras réplicas)
+	ShardsUpdated   int `json:"shards_updated"`
+	ShardsReloading int `json:"shards_reloading"`
+}
+
+// -----------------------------------------------------------
+// ENDPOINT: POST /ratings
+// -----------------------------------------------------------
+
+func handleRatings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", 405)
+		return
+	}
+
+	var req RatingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Cuerpo inválido", 400)
+		return
+	}
+
+	list := req.Ratings
+	if req.UserID != "" || req.MovieID != "" {
+		list = append(list, req.RatingInput)
+	}
+	if len(list) == 0 || len(list) > ratingsMaxBatch {
+		http.Error(w, fmt.Sprintf("Se requieren entre 1 y %d ratings", ratingsMaxBatch), 400)
+		return
+	}
+
+	now := time.Now().Unix()
+	docs := make([]any, 0, len(list))
+
+	for i, in := range list {
+		if in.UserID == "" {
+			http.Error(w, "Falta user_id", 400)
+			return
+		}
+		if _, ok := movieTitles[in.MovieID]; !ok {
+			http.Error(w, "Película desconocida: "+in.MovieID, 400)
+			return
+		}
+		if !validRating(in.Rating) {
+			http.Error(w, fmt.Sprintf("rating debe estar entre %g y %g", MinRating, MaxRating), 400)
+			return
+		}
+		if in.Timestamp < 0 {
+			http.Error(w, "timestamp inválido", 400)
+			return
+		}
+		if in.Timestamp == 0 {
+			list[i].Timestamp = now
+		}
+
+		docs = append(docs, database.RatingDocument{
+			UserID:        in.UserID,
+			MovieID:       in.MovieID,
+			Rating:        in.Rating,
+			TimestampUnix: list[i].Timestamp,
+		})
+	}
+
+	// Primero el almacenamiento durable: si falla no se aplica nada
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := database.RatingsCollection().InsertMany(ctx, docs); err != nil {
+		http.Error(w, "Error guardando ratings: "+err.Error(), 500)
+		return
+	}
+
+	resp := applyRatings(list)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// replayRatings suma a userRatings los ratings guardados por POST
+// /ratings, en el orden en que llegaron (el último de un usuario y una
+// película reemplaza a los anteriores y al del CSV).
+func replayRatings() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	stored, err := database.StoredRatings(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range stored {
+		if userRatings[r.UserID] == nil {
+			userRatings[r.UserID] = map[string]float64{}
+		}
+		userRatings[r.UserID][r.MovieID] = r.Rating
+	}
+
+	fmt.Println("Ratings recibidos por POST /ratings:", len(stored))
+	return nil
+}
+
+// -----------------------------------------------------------
+// Aplicar ratings: matriz, modelo MF y shards de los nodos
+// -----------------------------------------------------------
+
+// applyRatings calcula la asignación nueva serializado con rebalance y
+// con los demás lotes (cada uno parte del anterior, publicado o no), y
+// suelta el lock antes de enviar los updates: un nodo lento no frena
+// otros lotes ni un rebalanceo. Las versiones nuevas se publican recién
+// cuando las réplicas confirmaron los cambios; hasta entonces las
+// consultas siguen con la versión anterior, que los nodos aceptan
+// también después de aplicar el update.
+func applyRatings(list []RatingInput) RatingsResponse {
+	rebalanceMu.Lock()
+	change := updateMatrix(list)
+	users, items := change.users, change.items
+	foldInMF(users)
+
+	next, updates := nextAssignment(change)
+	assignMu.Lock()
+	latestAssignment = next
+	assignMu.Unlock()
+	rebalanceMu.Unlock()
+
+	failed := pushUpdates(updates)
+	publishAssignment(next)
+
+	// Las réplicas que no aplicaron el update reciben el shard completo,
+	// salvo que un lote o rebalanceo posterior ya lo haya reemplazado
+	// (ese envío se ocupa de la réplica)
+	reloads, missed := 0, 0
+	for addr, placements := range failed {
+		for _, sh := range placements {
+			missed++
+			if !isLatest(sh) {
+				continue
+			}
+			go reloadShard(addr, sh)
+			reloads++
+		}
+	}
+
+	resp := RatingsResponse{Recorded: len(list), Users: len(users), Movies: len(items)}
+	resp.ShardsReloading = reloads
+	for _, u := range updates {
+		resp.ShardsUpdated += len(u.shard.replicas)
+	}
+	resp.ShardsUpdated -= missed
+
+	fmt.Println("Ratings aplicados:", len(list), "de", len(users), "usuarios;",
+		resp.ShardsUpdated, "réplicas actualizadas,", resp.ShardsReloading, "recargando")
+	return resp
+}
+
+// ratingsChange: lo que cambió en la matriz con un lote de ratings
+type ratingsChange struct {
+	users, items map[string]map[string]float64 // vectores nuevos
+
+	// Medias que cambiaron y los mapas completos tras el lote
+	userMeans, itemMeans       map[string]float64
+	allUserMeans, allItemMeans map[string]float64
+}
+
+// updateMatrix reemplaza los vectores afectados por copias con los
+// ratings nuevos, recalcula las medias de esos usuarios y películas y
+// devuelve lo que cambió
+func updateMatrix(list []RatingInput) ratingsChange {
+	ratingsMu.Lock()
+	defer ratingsMu.Unlock()
+
+	users := map[string]map[string]float64{}
+	items := map[string]map[string]float64{}
+
+	for _, in := range list {
+		if users[in.UserID] == nil {
+			users[in.UserID] = cloneVector(userRatings[in.UserID])
+		}
+		users[in.UserID][in.MovieID] = in.Rating
+
+		if items[in.MovieID] == nil {
+			items[in.MovieID] = cloneVector(itemRatings[in.MovieID])
+		}
+		items[in.MovieID][in.UserID] = in.Rating
+	}
+
+	ratingsSeq++
+	for id, vec := range users {
+		userRatings[id] = vec
+	}
+	for id, vec := range items {
+		itemRatings[id] = vec
+		itemVersions[id] = itemEpoch + ratingsSeq
+	}
+
+	change := ratingsChange{
+		users:     users,
+		items:     items,
+		userMeans: knn.MeanRatings(users),
+		itemMeans: knn.MeanRatings(items),
+	}
+	userMeans = withMeans(userMeans, change.userMeans)
+	itemMeans = withMeans(itemMeans, change.itemMeans)
+	change.allUserMeans, change.allItemMeans = userMeans, itemMeans
+	return change
+}
+
+// withMeans devuelve una copia de means con las medias de changed
+func withMeans(means, changed map[string]float64) map[string]float64 {
+	next := make(map[string]float64, len(means)+len(changed))
+	for id, m := range means {
+		next[id] = m
+	}
+	for id, m := range changed {
+		next[id] = m
+	}
+	return next
+}
+
+func cloneVector(vec map[string]float64) map[string]float64 {
+	clone := make(map[string]float64, len(vec)+1)
+	for k, v := range vec {
+		clone[k] = v
+	}
+	return clone
+}
+
+// foldInMF vuelve a resolver en model=mf los vectores de los usuarios
+// con ratings nuevos; el modelo en disco cambia recién con el próximo
+// entrenamiento. FoldIn trabaja sobre una copia, así que el lock sólo
+// cubre el cambio de puntero.
+func foldInMF(users map[string]map[string]float64) {
+	base := currentMF()
+	if base == nil {
+		return
+	}
+	next := base.FoldIn(users, foldInReg)
+
+	mfMu.Lock()
+	defer mfMu.Unlock()
+
+	// Si mientras tanto terminó un entrenamiento se conserva ese modelo
+	if mfModel == base {
+		mfModel = next
+	}
+}
+
+// shardUpdate: shard con los vectores nuevos y lo que hay que enviar a
+// sus réplicas
+type shardUpdate struct {
+	shard   shardPlacement
+	version int64 // versión que tienen los nodos
+	vectors map[string]map[string]float64
+	means   map[string]float64
+}
+
+// isLatest indica si sh es la versión del shard en la última asignación
+func isLatest(sh shardPlacement) bool {
+	assignMu.RLock()
+	defer assignMu.RUnlock()
+
+	for _, cur := range latestAssignment.shards {
+		if cur.id == sh.id {
+			return cur.version == sh.version
+		}
+	}
+	return false
+}
+
+// nextAssignment arma la asignación con los vectores nuevos en los
+// shards de sus dueños y las medias nuevas en todos los shards que las
+// usan (las de películas en los de usuarios y viceversa). Cada shard
+// afectado se copia (puede estar enviándose a un nodo) y su versión se
+// ajusta sólo con lo que cambió.
+func nextAssignment(change ratingsChange) (shardAssignment, []shardUpdate) {
+	assignMu.RLock()
+	current := latestAssignment
+	assignMu.RUnlock()
+
+	if current.ring == nil {
+		// Sin nodos: los recibirán completos en el primer rebalanceo
+		return current, nil
+	}
+
+	changed := map[string]map[string]map[string]float64{}
+	add := func(kind string, vectors map[string]map[string]float64) {
+		for id, vec := range vectors {
+			shard := kind + "@" + current.ring.Owner(id)
+			if changed[shard] == nil {
+				changed[shard] = map[string]map[string]float64{}
+			}
+			changed[shard][id] = vec
+		}
+	}
+	add(network.ModeUser, change.users)
+	add(network.ModeItem, change.items)
+
+	next := shardAssignment{ring: current.ring, gen: current.gen + 1}
+	var updates []shardUpdate
+
+	for _, sh := range current.shards {
+		vectors := changed[sh.id]
+		means, allMeans := change.itemMeans, change.allItemMeans
+		if sh.kind == network.ModeItem {
+			means, allMeans = change.userMeans, change.allUserMeans
+		}
+		if len(vectors) == 0 && len(means) == 0 {
+			next.shards = append(next.shards, sh)
+			continue
+		}
+
+		updated := sh
+		v := uint64(sh.version)
+
+		if len(vectors) > 0 {
+			updated.users = make(map[string]map[string]float64, len(sh.users)+len(vectors))
+			for id, vec := range sh.users {
+				updated.users[id] = vec
+			}
+			for id, vec := range vectors {
+				if old, ok := sh.users[id]; ok {
+					v ^= vectorHash(id, old)
+				}
+				v ^= vectorHash(id, vec)
+				updated.users[id] = vec
+			}
+		}
+
+		for id, m := range means {
+			if old, ok := sh.means[id]; ok {
+				v ^= meanHash(id, old)
+			}
+			v ^= meanHash(id, m)
+		}
+		updated.means = allMeans
+		updated.version = int64(v)
+
+		next.shards = append(next.shards, updated)
+		updates = append(updates, shardUpdate{shard: updated, version: sh.version, vectors: vectors, means: means})
+	}
+
+	return next, updates
+}
+
+// pushUpdates envía los vectores nuevos a todas las réplicas en paralelo
+// y devuelve, por nodo, los shards que no los aplicaron (caído,
+// reiniciado o con otra versión).
+func pushUpdates(updates []shardUpdate) map[string][]shardPlacement {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := map[string][]shardPlacement{}
+
+	for _, u := range updates {
+		msg := network.Envelope{
+			Type: network.MsgUpdate,
+			Update: &network.UpdateRequest{
+				Shard:      u.shard.id,
+				Version:    u.version,
+				NewVersion: u.shard.version,
+				Vectors:    u.vectors,
+				Means:      u.means,
+			},
+		}
+
+		for _, addr := range u.shard.replicas {
+			wg.Add(1)
+			go func(addr string, sh shardPlacement) {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+				defer cancel()
+
+				reply, err := nodePool.Call(ctx, addr, msg)
+				if err == nil && reply.UpdateResult == nil {
+					err = fmt.Errorf("nodo %s respondió %q sin confirmar", addr, reply.Type)
+				}
+				if err != nil {
+					fmt.Println("Réplica", addr, "no aplicó", sh.id, ":", err)
+
+					mu.Lock()
+					failed[addr] = append(failed[addr], sh)
+					mu.Unlock()
+				}
+			}(addr, u.shard)
+		}
+	}
+
+	wg.Wait()
+	return failed
+}
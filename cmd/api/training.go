@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pcd-pc4/internal/mf"
+	"pcd-pc4/pkg/network"
+)
+
+// Plazo de un paso ALS sobre un shard (por réplica)
+const alsStepTimeout = 2 * time.Minute
+
+// -----------------------------------------------------------
+// Estado del último entrenamiento ALS distribuido
+// -----------------------------------------------------------
+
+type ALSStatus struct {
+	Running    bool      `json:"running"`
+	Factors    int       `json:"factors"`
+	Iterations int       `json:"iterations"`
+	Iteration  int       `json:"iteration"`
+	RMSE       []float64 `json:"rmse"` // RMSE de entrenamiento por iteración
+	Error      string    `json:"error,omitempty"`
+	Started    int64     `json:"started"`
+	Finished   int64     `json:"finished,omitempty"`
+}
+
+type alsParams struct {
+	factors    int
+	iterations int
+	reg        float64
+	tolerance  float64 // se detiene si el RMSE mejora menos que esto
+}
+
+var (
+	alsMu     sync.Mutex
+	alsStatus ALSStatus
+)
+
+func currentALSStatus() ALSStatus {
+	alsMu.Lock()
+	defer alsMu.Unlock()
+
+	status := alsStatus
+	status.RMSE = append([]float64(nil), alsStatus.RMSE...)
+	return status
+}
+
+// -----------------------------------------------------------
+// ENDPOINT: POST /train/als[?factors=&iterations=&reg=&tolerance=]
+//           GET  /train/als  (estado)
+// -----------------------------------------------------------
+
+func handleTrainALS(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(currentALSStatus())
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Método no permitido", 405)
+		return
+	}
+
+	params, err := parseALSParams(r)
+	if err != nil {
+		http.Error(w, "Parámetros inválidos: "+err.Error(), 400)
+		return
+	}
+
+	alsMu.Lock()
+	if alsStatus.Running {
+		alsMu.Unlock()
+		http.Error(w, "Ya hay un entrenamiento en curso", 409)
+		return
+	}
+	alsStatus = ALSStatus{
+		Running:    true,
+		Factors:    params.factors,
+		Iterations: params.iterations,
+		Started:    time.Now().Unix(),
+	}
+	alsMu.Unlock()
+
+	go runALS(params)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(currentALSStatus())
+}
+
+func parseALSParams(r *http.Request) (alsParams, error) {
+	def := mf.DefaultConfig()
+	p := alsParams{factors: def.Factors, iterations: 10, reg: 0.1, tolerance: 1e-4}
+	q := r.URL.Query()
+
+	if v := q.Get("factors"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("factors inválido: %s", v)
+		}
+		p.factors = n
+	}
+
+	if v := q.Get("iterations"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("iterations inválido: %s", v)
+		}
+		p.iterations = n
+	}
+
+	if v := q.Get("reg"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return p, fmt.Errorf("reg inválido: %s", v)
+		}
+		p.reg = f
+	}
+
+	if v := q.Get("tolerance"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return p, fmt.Errorf("tolerance inválido: %s", v)
+		}
+		p.tolerance = f
+	}
+
+	return p, nil
+}
+
+func runALS(params alsParams) {
+	model, err := trainALS(params)
+	if err == nil {
+		// El modelo entrenado pasa a servir model=mf
+		err = setMF(model)
+	}
+
+	alsMu.Lock()
+	alsStatus.Running = false
+	alsStatus.Finished = time.Now().Unix()
+	if err != nil {
+		alsStatus.Error = err.Error()
+	}
+	alsMu.Unlock()
+
+	if err != nil {
+		fmt.Println("Entrenamiento ALS falló:", err)
+		return
+	}
+	fmt.Println("Entrenamiento ALS completo; model=mf usa el modelo nuevo")
+}
+
+// -----------------------------------------------------------
+// Coordinador: alterna usuarios / películas sobre los nodos
+// -----------------------------------------------------------
+
+// trainALS difunde los vectores de películas para que cada nodo resuelva
+// los usuarios de su shard y luego, a la inversa, los de usuarios para
+// resolver las películas; repite hasta converger o agotar iteraciones.
+// Toda la corrida usa la asignación del inicio: un rebalanceo a mitad de
+// camino no mezcla shards de dos asignaciones distintas.
+func trainALS(params alsParams) (*mf.Model, error) {
+	assign := currentAssignment()
+	userShards := assign.ofKind(network.ModeUser)
+	itemShards := assign.ofKind(network.ModeItem)
+	if len(userShards) == 0 || len(itemShards) == 0 {
+		return nil, fmt.Errorf("no hay nodos ML disponibles")
+	}
+
+	// Media y películas de los mismos shards que resuelven los nodos
+	var sum float64
+	n := 0
+	for _, sh := range userShards {
+		for _, ratings := range sh.users {
+			for _, r := range ratings {
+				sum += r
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("no hay ratings para entrenar")
+	}
+	globalMean := sum / float64(n)
+
+	var itemIDs []string
+	for _, sh := range itemShards {
+		for id := range sh.users {
+			itemIDs = append(itemIDs, id)
+		}
+	}
+
+	items := mf.InitALSVectors(itemIDs, params.factors, 0.1, mf.DefaultConfig().Seed)
+	users := map[string][]float64{}
+
+	ctx := context.Background()
+	prev := math.Inf(1)
+
+	for it := 1; it <= params.iterations; it++ {
+		var err error
+
+		users, _, err = alsHalfStep(ctx, userShards, network.ModeUser, items, globalMean, params.reg)
+		if err != nil {
+			return nil, fmt.Errorf("iteración %d (usuarios): %w", it, err)
+		}
+
+		var rmse float64
+		items, rmse, err = alsHalfStep(ctx, itemShards, network.ModeItem, users, globalMean, params.reg)
+		if err != nil {
+			return nil, fmt.Errorf("iteración %d (películas): %w", it, err)
+		}
+
+		fmt.Printf("ALS iteración %d/%d  RMSE %.4f\n", it, params.iterations, rmse)
+
+		alsMu.Lock()
+		alsStatus.Iteration = it
+		alsStatus.RMSE = append(alsStatus.RMSE, rmse)
+		alsMu.Unlock()
+
+		if prev-rmse < params.tolerance {
+			break
+		}
+		prev = rmse
+	}
+
+	return mf.FromALS(users, items, params.factors, globalMean), nil
+}
+
+// alsHalfStep resuelve en paralelo los shards de un tipo con los
+// vectores del otro lado fijos; devuelve los vectores nuevos y el RMSE.
+func alsHalfStep(ctx context.Context, shards []shardPlacement, side string, fixed map[string][]float64, globalMean, reg float64) (map[string][]float64, float64, error) {
+	type stepResult struct {
+		shard shardPlacement
+		resp  *network.ALSResponse
+		err   error
+	}
+	results := make(chan stepResult, len(shards))
+
+	for _, sh := range shards {
+		go func(sh shardPlacement) {
+			msg := network.Envelope{
+				Type: network.MsgALSStep,
+				ALS: &network.ALSRequest{
+					Shard:      sh.id,
+					Version:    sh.version,
+					Side:       side,
+					GlobalMean: globalMean,
+					Reg:        reg,
+					Fixed:      fixedForShard(sh, fixed),
+				},
+			}
+
+			reply, err := callReplicas(ctx, sh, msg)
+			if err == nil && reply.ALSResult == nil {
+				err = fmt.Errorf("respuesta %q sin resultado ALS", reply.Type)
+			}
+			results <- stepResult{shard: sh, resp: reply.ALSResult, err: err}
+		}(sh)
+	}
+
+	solved := make(map[string][]float64)
+	var sq float64
+	count := 0
+	var errs []string
+
+	for range shards {
+		res := <-results
+		if res.err != nil {
+			errs = append(errs, res.shard.id+": "+res.err.Error())
+			continue
+		}
+		for id, vec := range res.resp.Vectors {
+			solved[id] = vec
+		}
+		sq += res.resp.SquaredError
+		count += res.resp.Ratings
+	}
+
+	// Un shard sin resolver dejaría usuarios o películas sin vector
+	if len(errs) > 0 {
+		return nil, 0, errors.New(strings.Join(errs, "; "))
+	}
+	if count == 0 {
+		return solved, 0, nil
+	}
+	return solved, math.Sqrt(sq / float64(count)), nil
+}
+
+// fixedForShard envía sólo los vectores que el shard necesita (los ids
+// que aparecen en sus ratings).
+func fixedForShard(sh shardPlacement, fixed map[string][]float64) map[string][]float64 {
+	subset := make(map[string][]float64)
+	for _, ratings := range sh.users {
+		for id := range ratings {
+			if vec, ok := fixed[id]; ok {
+				subset[id] = vec
+			}
+		}
+	}
+	return subset
+}
+
+// callReplicas prueba las réplicas del shard en orden hasta que una
+// responde; las que perdieron el shard se recargan en segundo plano,
+// salvo que sh ya no sea la versión vigente (no se les manda una vieja).
+func callReplicas(ctx context.Context, sh shardPlacement, msg network.Envelope) (network.Envelope, error) {
+	var errs []string
+
+	for _, addr := range sh.replicas {
+		stepCtx, cancel := context.WithTimeout(ctx, alsStepTimeout)
+		reply, err := nodePool.Call(stepCtx, addr, msg)
+		cancel()
+
+		if err == nil {
+			return reply, nil
+		}
+
+		var remote *network.RemoteError
+		if errors.As(err, &remote) && remote.Code == network.ErrCodeShardNotLoaded && isCurrent(sh) {
+			go reloadShard(addr, sh)
+		}
+		errs = append(errs, addr+": "+err.Error())
+	}
+
+	return network.Envelope{}, errors.New(strings.Join(errs, "; "))
+}
+
+// isCurrent indica si sh sigue en la asignación vigente con esa versión
+func isCurrent(sh shardPlacement) bool {
+	for _, cur := range currentAssignment().shards {
+		if cur.id == sh.id {
+			return cur.version == sh.version
+		}
+	}
+	return false
+}
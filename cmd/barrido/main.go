@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"pcd-pc4/internal/env"
+	"pcd-pc4/internal/eval"
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/pkg/database"
+)
+
+// Barrido de hiperparámetros del KNN con validación cruzada k-fold: cada
+// combinación de SWEEP_K x SWEEP_SIMILARITY x SWEEP_MIN_OVERLAP x
+// SWEEP_PREDICTION se evalúa en las SWEEP_FOLDS particiones (en paralelo)
+// y se ordena por SWEEP_METRIC. Deja SWEEP_OUT/leaderboard.csv y .json
+// para elegir con datos los valores por defecto del API (K, similitud,
+// MIN_OVERLAP, PREDICTION). Con MONGO_URI se suman al CSV los ratings
+// recibidos por POST /ratings.
+
+// Combinación de hiperparámetros
+type Config struct {
+	Model      string `json:"model"`
+	K          int    `json:"k"`
+	Similarity string `json:"similarity"`
+	MinOverlap int    `json:"min_overlap"`
+	Prediction string `json:"prediction,omitempty"` // sólo user-based
+}
+
+// Fila del leaderboard: métricas promediadas sobre las particiones
+type Entry struct {
+	Rank   int         `json:"rank"`
+	Config Config      `json:"config"`
+	Metric float64     `json:"metric"`
+	Std    float64     `json:"std"` // desviación de la métrica entre particiones
+	Mean   eval.Report `json:"mean"`
+}
+
+type Leaderboard struct {
+	Metric  string  `json:"metric"`
+	Folds   int     `json:"folds"`
+	TopK    int     `json:"top_k"`
+	Entries []Entry `json:"entries"`
+}
+
+func main() {
+	ratingsPath := os.Getenv("RATINGS_PATH")
+	if ratingsPath == "" {
+		ratingsPath = "data/clean/ratings.csv"
+	}
+
+	outDir := os.Getenv("SWEEP_OUT")
+	if outDir == "" {
+		outDir = "sweep"
+	}
+
+	model := os.Getenv("SWEEP_MODEL")
+	if model == "" {
+		model = "user"
+	}
+	if model != "user" && model != "item" {
+		log.Fatal("SWEEP_MODEL debe ser user o item: ", model)
+	}
+
+	metric := os.Getenv("SWEEP_METRIC")
+	if metric == "" {
+		metric = "rmse"
+	}
+	if _, _, err := eval.Metric(eval.Report{}, metric); err != nil {
+		log.Fatal("SWEEP_METRIC inválido: ", err)
+	}
+
+	folds := env.Int("SWEEP_FOLDS", 5, 2)
+	seed := int64(env.Int("EVAL_SEED", 42, 0))
+
+	opts := eval.Options{
+		K:         env.Int("EVAL_K", 10, 1),
+		Relevance: env.Float("EVAL_RELEVANCE", 4, 0),
+		MaxUsers:  env.Int("EVAL_MAX_USERS", 0, 0),
+		Seed:      seed,
+	}
+
+	// Las particiones corren a la vez; los núcleos se reparten entre ellas
+	opts.Workers = runtime.NumCPU() / folds
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	configs := grid(model)
+
+	fmt.Println("Cargando ratings de", ratingsPath, "...")
+
+	ratings := knn.LoadRatings(ratingsPath)
+	ratings, stored, err := database.MergeStoredRatings(os.Getenv("MONGO_URI"), ratings)
+	if err != nil {
+		log.Fatal("Error cargando ratings de MongoDB: ", err)
+	}
+	if stored > 0 {
+		fmt.Println("Ratings recibidos por POST /ratings:", stored)
+	}
+	if len(ratings) == 0 {
+		log.Fatal("No se pudieron cargar ratings.")
+	}
+
+	splits, err := eval.KFold(ratings, folds, seed)
+	if err != nil {
+		log.Fatal("Error partiendo ratings: ", err)
+	}
+
+	fmt.Printf("Barrido %s: %d combinaciones x %d particiones, métrica %s\n",
+		model, len(configs), folds, metric)
+
+	start := time.Now()
+	board := Leaderboard{Metric: metric, Folds: folds, TopK: opts.K}
+
+	for i, cfg := range configs {
+		reports := crossValidate(cfg, splits, opts)
+
+		entry := Entry{Config: cfg, Mean: eval.MeanReport(reports)}
+		entry.Metric, _, _ = eval.Metric(entry.Mean, metric)
+		entry.Std = metricStd(reports, metric, entry.Metric)
+		board.Entries = append(board.Entries, entry)
+
+		fmt.Printf("[%d/%d] k=%d sim=%s overlap=%d pred=%s  %s %.4f ± %.4f\n",
+			i+1, len(configs), cfg.K, cfg.Similarity, cfg.MinOverlap, cfg.Prediction,
+			metric, entry.Metric, entry.Std)
+	}
+
+	rank(board.Entries, metric)
+
+	fmt.Println("Barrido completo en", time.Since(start).Round(time.Millisecond))
+	for _, e := range board.Entries {
+		if e.Rank > 5 {
+			break
+		}
+		fmt.Printf("  #%d k=%d sim=%s overlap=%d pred=%s  %s %.4f\n",
+			e.Rank, e.Config.K, e.Config.Similarity, e.Config.MinOverlap, e.Config.Prediction, metric, e.Metric)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		log.Fatal("Error creando ", outDir, ": ", err)
+	}
+	saveLeaderboardCSV(filepath.Join(outDir, "leaderboard.csv"), board)
+	saveLeaderboardJSON(filepath.Join(outDir, "leaderboard.json"), board)
+
+	fmt.Println("Leaderboard guardado en", outDir)
+}
+
+// ---------------------------------------------------------
+// Grilla y validación cruzada
+// ---------------------------------------------------------
+
+func grid(model string) []Config {
+	ks := env.Ints("SWEEP_K", "10,20,50,100", 1)
+	sims := env.List("SWEEP_SIMILARITY", "cosine,pearson,adjusted_cosine")
+	overlaps := env.Ints("SWEEP_MIN_OVERLAP", "0,3,5", 0)
+
+	// item-based no usa método de predicción
+	preds := []string{""}
+	if model == "user" {
+		preds = env.List("SWEEP_PREDICTION", "weighted,mean_centered,zscore")
+	}
+
+	for _, s := range sims {
+		if _, err := knn.NewSimilarity(s, nil); err != nil {
+			log.Fatal("SWEEP_SIMILARITY inválido: ", err)
+		}
+	}
+	for _, p := range preds {
+		if err := knn.CheckPredictMethod(p); err != nil {
+			log.Fatal("SWEEP_PREDICTION inválido: ", err)
+		}
+	}
+
+	var configs []Config
+	for _, k := range ks {
+		for _, s := range sims {
+			for _, o := range overlaps {
+				for _, p := range preds {
+					configs = append(configs, Config{Model: model, K: k, Similarity: s, MinOverlap: o, Prediction: p})
+				}
+			}
+		}
+	}
+	return configs
+}
+
+func newRecommender(cfg Config) eval.Recommender {
+	filter := knn.NeighborFilter{
+		MinOverlap:   cfg.MinOverlap,
+		Significance: env.Int("SIGNIFICANCE_N", 0, 0),
+		Shrinkage:    env.Float("SHRINKAGE", 0, 0),
+	}
+
+	if cfg.Model == "item" {
+		return &eval.ItemKNN{K: cfg.K, Similarity: cfg.Similarity, Filter: filter}
+	}
+	return &eval.UserKNN{
+		K:          cfg.K,
+		Similarity: cfg.Similarity,
+		Filter:     filter,
+		Predict:    knn.PredictOptions{Method: cfg.Prediction, MinSupport: env.Int("MIN_SUPPORT", knn.DefaultMinSupport, 0)},
+	}
+}
+
+// crossValidate evalúa cfg en cada partición, una goroutine por partición
+func crossValidate(cfg Config, splits []eval.Split, opts eval.Options) []eval.Report {
+	reports := make([]eval.Report, len(splits))
+
+	var wg sync.WaitGroup
+	for i, split := range splits {
+		wg.Add(1)
+		go func(i int, split eval.Split) {
+			defer wg.Done()
+
+			rep, err := eval.Evaluate(newRecommender(cfg), split, opts)
+			if err != nil {
+				log.Fatal("Error evaluando partición ", i, ": ", err)
+			}
+			reports[i] = rep
+		}(i, split)
+	}
+	wg.Wait()
+
+	return reports
+}
+
+func metricStd(reports []eval.Report, metric string, mean float64) float64 {
+	var sum float64
+	for _, r := range reports {
+		v, _, _ := eval.Metric(r, metric)
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(reports)))
+}
+
+// rank ordena de mejor a peor. Con métricas de error, una combinación
+// que no predijo ningún rating (RMSE 0) va al final.
+func rank(entries []Entry, metric string) {
+	_, lowerIsBetter, _ := eval.Metric(eval.Report{}, metric)
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if lowerIsBetter {
+			if (a.Mean.Predicted == 0) != (b.Mean.Predicted == 0) {
+				return b.Mean.Predicted == 0
+			}
+			return a.Metric < b.Metric
+		}
+		return a.Metric > b.Metric
+	})
+
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+}
+
+// ---------------------------------------------------------
+// Salida
+// ---------------------------------------------------------
+
+func saveLeaderboardCSV(path string, board Leaderboard) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal("Error creando ", path, ": ", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"rank", "model", "k", "similarity", "min_overlap", "prediction", "metric", "std",
+		"predicted", "rmse", "mae", "precision", "recall", "map", "ndcg", "hit_rate", "coverage", "seconds"})
+
+	for _, e := range board.Entries {
+		r := e.Mean
+		w.Write([]string{
+			strconv.Itoa(e.Rank),
+			e.Config.Model,
+			strconv.Itoa(e.Config.K),
+			e.Config.Similarity,
+			strconv.Itoa(e.Config.MinOverlap),
+			e.Config.Prediction,
+			fmt.Sprintf("%.4f", e.Metric),
+			fmt.Sprintf("%.4f", e.Std),
+			strconv.Itoa(r.Predicted),
+			fmt.Sprintf("%.4f", r.RMSE),
+			fmt.Sprintf("%.4f", r.MAE),
+			fmt.Sprintf("%.4f", r.Precision),
+			fmt.Sprintf("%.4f", r.Recall),
+			fmt.Sprintf("%.4f", r.MAP),
+			fmt.Sprintf("%.4f", r.NDCG),
+			fmt.Sprintf("%.4f", r.HitRate),
+			fmt.Sprintf("%.4f", r.Coverage),
+			fmt.Sprintf("%.2f", r.Seconds),
+		})
+	}
+	w.Flush()
+}
+
+func saveLeaderboardJSON(path string, board Leaderboard) {
+	data, err := json.MarshalIndent(board, "", "  ")
+	if err != nil {
+		log.Fatal("Error codificando leaderboard: ", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Fatal("Error escribiendo ", path, ": ", err)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"pcd-pc4/internal/bpr"
+	"pcd-pc4/internal/content"
+	"pcd-pc4/internal/env"
+	"pcd-pc4/internal/hybrid"
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/internal/mf"
+	"pcd-pc4/internal/popularity"
+	"pcd-pc4/pkg/database"
+	"pcd-pc4/pkg/network"
+)
+
+// Entrena offline los modelos que sirve el API con
+// /recommend/:userID?model=mf|bpr|hybrid. TRAIN_MODEL elige cuál (mf
+// por defecto) y los hiperparámetros van por variables de entorno:
+// MF_FACTORS, MF_EPOCHS, MF_LR, MF_REG o BPR_FACTORS, BPR_EPOCHS, ...
+// Con MONGO_URI se suman al CSV los ratings recibidos por POST /ratings.
+func main() {
+	ratingsPath := os.Getenv("RATINGS_PATH")
+	if ratingsPath == "" {
+		ratingsPath = "data/clean/ratings.csv"
+	}
+
+	switch m := os.Getenv("TRAIN_MODEL"); m {
+	case "", "mf":
+		trainMF(ratingsPath)
+	case "bpr":
+		trainBPR(ratingsPath)
+	case "hybrid":
+		trainHybrid(ratingsPath)
+	default:
+		log.Fatal("TRAIN_MODEL debe ser mf, bpr o hybrid: ", m)
+	}
+}
+
+// loadRatings lee el CSV y le suma los ratings guardados en MongoDB
+func loadRatings(path string) map[string]map[string]float64 {
+	ratings, stored, err := database.MergeStoredRatings(os.Getenv("MONGO_URI"), knn.LoadRatings(path))
+	if err != nil {
+		log.Fatal("Error cargando ratings de MongoDB: ", err)
+	}
+	if stored > 0 {
+		fmt.Println("Ratings recibidos por POST /ratings:", stored)
+	}
+	return knn.RatingsMatrix(ratings)
+}
+
+// ---------------------------------------------------------
+// Factorización matricial sobre ratings explícitos
+// ---------------------------------------------------------
+
+func trainMF(ratingsPath string) {
+	modelPath := os.Getenv("MF_MODEL_PATH")
+	if modelPath == "" {
+		modelPath = "models/mf.gob"
+	}
+
+	cfg := mf.DefaultConfig()
+	cfg.Factors = env.Int("MF_FACTORS", cfg.Factors, 1)
+	cfg.Epochs = env.Int("MF_EPOCHS", cfg.Epochs, 1)
+	cfg.LearningRate = env.Float("MF_LR", cfg.LearningRate, 0)
+	cfg.Reg = env.Float("MF_REG", cfg.Reg, 0)
+
+	fmt.Println("Cargando ratings de", ratingsPath, "...")
+
+	ratings := loadRatings(ratingsPath)
+	if len(ratings) == 0 {
+		log.Fatal("No se pudieron cargar ratings.")
+	}
+
+	fmt.Printf("Entrenando MF: %d factores, %d épocas, lr=%g, reg=%g\n",
+		cfg.Factors, cfg.Epochs, cfg.LearningRate, cfg.Reg)
+
+	start := time.Now()
+	model := mf.Train(ratings, cfg, func(epoch int, rmse float64) {
+		fmt.Printf("Época %d/%d  RMSE entrenamiento %.4f\n", epoch, cfg.Epochs, rmse)
+	})
+
+	fmt.Println("Entrenamiento completo en", time.Since(start).Round(time.Millisecond),
+		"-", len(model.Users), "usuarios,", len(model.Items), "películas")
+
+	if err := model.Save(modelPath); err != nil {
+		log.Fatal("Error guardando modelo: ", err)
+	}
+
+	fmt.Println("Modelo guardado en", modelPath)
+}
+
+// ---------------------------------------------------------
+// BPR sobre interacciones implícitas (vistas, clics). Sin log
+// de interacciones se usan los ratings >= BPR_MIN_RATING.
+// ---------------------------------------------------------
+
+func trainBPR(ratingsPath string) {
+	modelPath := os.Getenv("BPR_MODEL_PATH")
+	if modelPath == "" {
+		modelPath = "models/bpr.gob"
+	}
+
+	interactionsPath := os.Getenv("INTERACTIONS_PATH")
+	if interactionsPath == "" {
+		interactionsPath = "data/clean/interactions.csv"
+	}
+
+	cfg := bpr.DefaultConfig()
+	cfg.Factors = env.Int("BPR_FACTORS", cfg.Factors, 1)
+	cfg.Epochs = env.Int("BPR_EPOCHS", cfg.Epochs, 1)
+	cfg.LearningRate = env.Float("BPR_LR", cfg.LearningRate, 0)
+	cfg.Reg = env.Float("BPR_REG", cfg.Reg, 0)
+
+	in, err := bpr.LoadInteractions(interactionsPath)
+	if err != nil {
+		minRating := env.Float("BPR_MIN_RATING", 4, 0)
+		fmt.Println("Sin interacciones en", interactionsPath, "(", err, ") - usando ratings >=", minRating)
+
+		ratings := loadRatings(ratingsPath)
+		if len(ratings) == 0 {
+			log.Fatal("No se pudieron cargar ratings.")
+		}
+		in = bpr.FromRatings(ratings, minRating)
+	}
+
+	fmt.Printf("Entrenando BPR: %d usuarios, %d factores, %d épocas, lr=%g, reg=%g\n",
+		len(in), cfg.Factors, cfg.Epochs, cfg.LearningRate, cfg.Reg)
+
+	start := time.Now()
+	model := bpr.Train(in, cfg, func(epoch int, loss float64) {
+		fmt.Printf("Época %d/%d  pérdida BPR %.4f\n", epoch, cfg.Epochs, loss)
+	})
+
+	fmt.Println("Entrenamiento completo en", time.Since(start).Round(time.Millisecond),
+		"-", len(model.Users), "usuarios,", len(model.Items), "películas")
+
+	if err := model.Save(modelPath); err != nil {
+		log.Fatal("Error guardando modelo: ", err)
+	}
+
+	fmt.Println("Modelo guardado en", modelPath)
+}
+
+// ---------------------------------------------------------
+// Pesos del ranker híbrido: se retiene una parte de los ratings
+// de HYBRID_USERS usuarios y se ajusta rating ≈ Σ w·componente
+// con las puntuaciones que cada modelo da a esas películas
+// ---------------------------------------------------------
+
+func trainHybrid(ratingsPath string) {
+	weightsPath := os.Getenv("HYBRID_WEIGHTS_PATH")
+	if weightsPath == "" {
+		weightsPath = "models/hybrid.json"
+	}
+
+	sampleUsers := env.Int("HYBRID_USERS", 200, 1)
+	holdout := env.Float("HYBRID_HOLDOUT", 0.2, 0)
+	if holdout <= 0 || holdout >= 1 {
+		log.Fatal("HYBRID_HOLDOUT debe estar entre 0 y 1")
+	}
+
+	fmt.Println("Cargando ratings de", ratingsPath, "...")
+
+	ratings := loadRatings(ratingsPath)
+	if len(ratings) == 0 {
+		log.Fatal("No se pudieron cargar ratings.")
+	}
+
+	// Split de validación reproducible
+	rng := rand.New(rand.NewSource(42))
+
+	users := make([]string, 0, len(ratings))
+	for u, rs := range ratings {
+		if len(rs) >= 10 {
+			users = append(users, u)
+		}
+	}
+	sort.Strings(users)
+	rng.Shuffle(len(users), func(i, j int) { users[i], users[j] = users[j], users[i] })
+	if len(users) > sampleUsers {
+		users = users[:sampleUsers]
+	}
+
+	train := make(map[string]map[string]float64, len(ratings))
+	for u, rs := range ratings {
+		train[u] = rs
+	}
+
+	validation := make(map[string]map[string]float64, len(users))
+	for _, u := range users {
+		movies := make([]string, 0, len(ratings[u]))
+		for m := range ratings[u] {
+			movies = append(movies, m)
+		}
+		sort.Strings(movies)
+		rng.Shuffle(len(movies), func(i, j int) { movies[i], movies[j] = movies[j], movies[i] })
+
+		held := int(float64(len(movies)) * holdout)
+		if held < 1 {
+			held = 1
+		}
+
+		kept := make(map[string]float64, len(movies)-held)
+		validation[u] = make(map[string]float64, held)
+		for i, m := range movies {
+			if i < held {
+				validation[u][m] = ratings[u][m]
+			} else {
+				kept[m] = ratings[u][m]
+			}
+		}
+		train[u] = kept
+	}
+
+	// Componentes entrenados sólo con el split de entrenamiento
+	pop := popularity.Build(knn.TransposeRatings(train), 10)
+
+	terms := content.LoadMovieTerms("data/clean/movies.csv")
+	content.AddTags(terms, "data/clean/tags.csv")
+	cb := content.Build(terms)
+
+	fmt.Println("Ajustando pesos híbridos con", len(users), "usuarios de validación...")
+
+	// Mismo mínimo de vecinos por película que el API
+	predict := knn.PredictOptions{MinSupport: env.Int("MIN_SUPPORT", knn.DefaultMinSupport, 0)}
+
+	var examples []hybrid.Example
+	for _, u := range users {
+		neighbors := []network.NeighborResult{}
+		for other, rs := range train {
+			if other == u {
+				continue
+			}
+			if sim := knn.CosineSimilarity(train[u], rs); sim > 0 {
+				neighbors = append(neighbors, network.NeighborResult{UserID: other, Similarity: sim})
+			}
+		}
+		predicted := map[string]float64{}
+		for _, r := range knn.PredictRatingsWith(u, train, knn.TopK(neighbors, 50), predict) {
+			predicted[r.MovieID] = r.Predicted
+		}
+
+		profile := cb.UserProfile(train[u])
+
+		for movie, rating := range validation[u] {
+			// Sin predicción KNN no hay ejemplo completo
+			p, ok := predicted[movie]
+			if !ok {
+				continue
+			}
+			examples = append(examples, hybrid.Example{
+				Components: map[string]float64{
+					hybrid.CompKNN:        hybrid.Scale(hybrid.CompKNN, p),
+					hybrid.CompContent:    hybrid.Scale(hybrid.CompContent, cb.Score(profile, movie)),
+					hybrid.CompPopularity: hybrid.Scale(hybrid.CompPopularity, pop.Score(movie)),
+				},
+				Rating: rating,
+			})
+		}
+	}
+
+	fitted, err := hybrid.Fit(examples, []string{hybrid.CompKNN, hybrid.CompContent, hybrid.CompPopularity})
+	if err != nil {
+		log.Fatal("No se pudieron ajustar los pesos: ", err)
+	}
+
+	fmt.Printf("Pesos: knn=%.3f content=%.3f popularity=%.3f (%d ejemplos, RMSE %.4f)\n",
+		fitted.Weights[hybrid.CompKNN], fitted.Weights[hybrid.CompContent], fitted.Weights[hybrid.CompPopularity],
+		fitted.Examples, fitted.RMSE)
+
+	if err := fitted.Save(weightsPath); err != nil {
+		log.Fatal("Error guardando pesos: ", err)
+	}
+
+	fmt.Println("Pesos guardados en", weightsPath)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"pcd-pc4/internal/bpr"
+	"pcd-pc4/internal/content"
+	"pcd-pc4/internal/env"
+	"pcd-pc4/internal/eval"
+	"pcd-pc4/internal/hybrid"
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/internal/mf"
+	"pcd-pc4/pkg/database"
+)
+
+// Evaluación offline de los recomendadores: parte ratings.csv en train
+// y test, entrena cada modelo de EVAL_MODELS con train y mide error
+// (RMSE, MAE) y ranking (precision@K, recall@K, MAP, NDCG, hit rate,
+// cobertura) sobre test. Deja EVAL_OUT/report.csv y report.json.
+//
+// EVAL_SPLIT elige la partición: random (EVAL_TEST_FRACTION),
+// leave_k_out (EVAL_LEAVE_K por usuario) o timestamp (la fracción más
+// reciente). KNN usa las mismas variables que el API (MIN_OVERLAP,
+// SIGNIFICANCE_N, SHRINKAGE, PREDICTION, MIN_SUPPORT).
+//
+// Con EVAL_NODES=nodo1:9001,nodo2:9002 los modelos KNN se evalúan
+// repartiendo los usuarios de test entre esos nodos (ver remote.go).
+//
+// Con MONGO_URI se suman al CSV los ratings recibidos por POST /ratings.
+
+// Informe completo con la configuración usada
+type Output struct {
+	Split     eval.SplitConfig `json:"split"`
+	K         int              `json:"k"`
+	Relevance float64          `json:"relevance"`
+	Train     int              `json:"train_users"`
+	Test      int              `json:"test_users"`
+	Reports   []eval.Report    `json:"reports"`
+}
+
+func main() {
+	ratingsPath := os.Getenv("RATINGS_PATH")
+	if ratingsPath == "" {
+		ratingsPath = "data/clean/ratings.csv"
+	}
+
+	outDir := os.Getenv("EVAL_OUT")
+	if outDir == "" {
+		outDir = "evaluation"
+	}
+
+	splitCfg := eval.SplitConfig{
+		Method:       os.Getenv("EVAL_SPLIT"),
+		TestFraction: env.Float("EVAL_TEST_FRACTION", 0.2, 0),
+		LeaveK:       env.Int("EVAL_LEAVE_K", 5, 1),
+		Seed:         int64(env.Int("EVAL_SEED", 42, 0)),
+	}
+	if splitCfg.Method == "" {
+		splitCfg.Method = eval.SplitRandom
+	}
+
+	opts := eval.Options{
+		K:         env.Int("EVAL_K", 10, 1),
+		Relevance: env.Float("EVAL_RELEVANCE", 4, 0),
+		Workers:   env.Int("EVAL_WORKERS", runtime.NumCPU(), 1),
+		MaxUsers:  env.Int("EVAL_MAX_USERS", 0, 0),
+		Seed:      splitCfg.Seed,
+	}
+
+	models := os.Getenv("EVAL_MODELS")
+	if models == "" {
+		models = "user,item,mf,popularity"
+	}
+
+	recs := []eval.Recommender{}
+	for _, name := range strings.Split(models, ",") {
+		recs = append(recs, newRecommender(strings.TrimSpace(name)))
+	}
+
+	fmt.Println("Cargando ratings de", ratingsPath, "...")
+
+	ratings := knn.LoadRatings(ratingsPath)
+	ratings, stored, err := database.MergeStoredRatings(os.Getenv("MONGO_URI"), ratings)
+	if err != nil {
+		log.Fatal("Error cargando ratings de MongoDB: ", err)
+	}
+	if stored > 0 {
+		fmt.Println("Ratings recibidos por POST /ratings:", stored)
+	}
+	if len(ratings) == 0 {
+		log.Fatal("No se pudieron cargar ratings.")
+	}
+
+	split, err := eval.MakeSplit(ratings, splitCfg)
+	if err != nil {
+		log.Fatal("Error partiendo ratings: ", err)
+	}
+
+	fmt.Printf("Split %s: %d usuarios en train, %d en test (K=%d, relevantes >= %g)\n",
+		splitCfg.Method, len(split.Train), len(split.Test), opts.K, opts.Relevance)
+
+	out := Output{
+		Split:     splitCfg,
+		K:         opts.K,
+		Relevance: opts.Relevance,
+		Train:     len(split.Train),
+		Test:      len(split.Test),
+	}
+
+	splitID := fmt.Sprintf("%s-%d-%d", splitCfg.Method, splitCfg.Seed, time.Now().UnixNano())
+	remote := newRemoteEval(splitID, split.Train)
+	loaded := false
+
+	for _, rec := range recs {
+		task, distributed := remoteTask(rec, opts)
+		distributed = distributed && remote != nil
+
+		if distributed && !loaded {
+			fmt.Println("Enviando split de train a", len(remote.nodes), "nodos...")
+			if err := remote.loadAll(); err != nil {
+				log.Fatal("Error cargando el split en los nodos: ", err)
+			}
+			loaded = true
+		}
+
+		var rep eval.Report
+		var err error
+		if distributed {
+			fmt.Println("Evaluando", rec.Name(), "en", len(remote.nodes), "nodos ...")
+			rep, err = remote.evaluate(rec.Name(), task, split, opts)
+		} else {
+			fmt.Println("Evaluando", rec.Name(), "...")
+			rep, err = eval.Evaluate(rec, split, opts)
+		}
+		if err != nil {
+			log.Fatal("Error evaluando ", rec.Name(), ": ", err)
+		}
+
+		fmt.Printf("  RMSE %.4f  MAE %.4f  P@%d %.4f  R@%d %.4f  MAP %.4f  NDCG %.4f  hit %.4f  cob %.4f  (%.1fs)\n",
+			rep.RMSE, rep.MAE, opts.K, rep.Precision, opts.K, rep.Recall, rep.MAP, rep.NDCG,
+			rep.HitRate, rep.Coverage, rep.Seconds)
+
+		out.Reports = append(out.Reports, rep)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		log.Fatal("Error creando ", outDir, ": ", err)
+	}
+	saveReportCSV(filepath.Join(outDir, "report.csv"), out.Reports)
+	saveReportJSON(filepath.Join(outDir, "report.json"), out)
+
+	fmt.Println("Resultados guardados en", outDir)
+}
+
+// ---------------------------------------------------------
+// Modelos evaluables
+// ---------------------------------------------------------
+
+func newRecommender(name string) eval.Recommender {
+	switch name {
+	case "user":
+		return newUserKNN()
+	case "item":
+		return &eval.ItemKNN{
+			K:          env.Int("EVAL_NEIGHBORS", 50, 1),
+			Similarity: os.Getenv("EVAL_SIMILARITY"),
+			Filter:     neighborFilter(),
+		}
+	case "mf":
+		cfg := mf.DefaultConfig()
+		cfg.Factors = env.Int("MF_FACTORS", cfg.Factors, 1)
+		cfg.Epochs = env.Int("MF_EPOCHS", cfg.Epochs, 1)
+		cfg.LearningRate = env.Float("MF_LR", cfg.LearningRate, 0)
+		cfg.Reg = env.Float("MF_REG", cfg.Reg, 0)
+		return &eval.MF{Config: cfg}
+	case "bpr":
+		cfg := bpr.DefaultConfig()
+		cfg.Factors = env.Int("BPR_FACTORS", cfg.Factors, 1)
+		cfg.Epochs = env.Int("BPR_EPOCHS", cfg.Epochs, 1)
+		cfg.LearningRate = env.Float("BPR_LR", cfg.LearningRate, 0)
+		cfg.Reg = env.Float("BPR_REG", cfg.Reg, 0)
+		return &eval.BPR{Config: cfg, MinRating: env.Float("BPR_MIN_RATING", 4, 0)}
+	case "content":
+		return &eval.Content{Model: contentModel()}
+	case "popularity":
+		return &eval.Popularity{MinVotes: env.Float("POPULARITY_MIN_VOTES", 10, 0)}
+	case "hybrid":
+		strategy := os.Getenv("HYBRID_STRATEGY")
+		if strategy == "" {
+			strategy = hybrid.StrategyWeighted
+		}
+		return &eval.Hybrid{
+			KNN:      newUserKNN(),
+			Content:  contentModel(),
+			MinVotes: env.Float("POPULARITY_MIN_VOTES", 10, 0),
+			Weights:  hybridWeights(),
+			Strategy: strategy,
+		}
+	default:
+		log.Fatal("Modelo desconocido en EVAL_MODELS: ", name)
+		return nil
+	}
+}
+
+func newUserKNN() *eval.UserKNN {
+	predict := knn.PredictOptions{
+		Method:     os.Getenv("PREDICTION"),
+		MinSupport: env.Int("MIN_SUPPORT", knn.DefaultMinSupport, 0),
+	}
+	if err := knn.CheckPredictMethod(predict.Method); err != nil {
+		log.Fatal("PREDICTION inválido: ", err)
+	}
+
+	return &eval.UserKNN{
+		K:          env.Int("EVAL_NEIGHBORS", 50, 1),
+		Similarity: os.Getenv("EVAL_SIMILARITY"),
+		Filter:     neighborFilter(),
+		Predict:    predict,
+	}
+}
+
+func neighborFilter() knn.NeighborFilter {
+	return knn.NeighborFilter{
+		MinOverlap:   env.Int("MIN_OVERLAP", 0, 0),
+		Significance: env.Int("SIGNIFICANCE_N", 0, 0),
+		Shrinkage:    env.Float("SHRINKAGE", 0, 0),
+	}
+}
+
+func contentModel() *content.Model {
+	terms := content.LoadMovieTerms("data/clean/movies.csv")
+	content.AddTags(terms, "data/clean/tags.csv")
+	return content.Build(terms)
+}
+
+// Pesos aprendidos por entrenar si existen; si no, los de por defecto
+func hybridWeights() hybrid.Weights {
+	path := os.Getenv("HYBRID_WEIGHTS_PATH")
+	if path == "" {
+		path = "models/hybrid.json"
+	}
+
+	fitted, err := hybrid.LoadFitted(path)
+	if err != nil {
+		fmt.Println("Sin pesos híbridos en", path, "- usando los de por defecto")
+		return hybrid.DefaultWeights()
+	}
+	return fitted.Weights
+}
+
+// ---------------------------------------------------------
+// Salida
+// ---------------------------------------------------------
+
+func saveReportCSV(path string, reports []eval.Report) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal("Error creando ", path, ": ", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"model", "users", "predicted", "rmse", "mae", "precision", "recall", "map", "ndcg", "hit_rate", "coverage", "seconds"})
+
+	for _, r := range reports {
+		w.Write([]string{
+			r.Model,
+			strconv.Itoa(r.Users),
+			strconv.Itoa(r.Predicted),
+			fmt.Sprintf("%.4f", r.RMSE),
+			fmt.Sprintf("%.4f", r.MAE),
+			fmt.Sprintf("%.4f", r.Precision),
+			fmt.Sprintf("%.4f", r.Recall),
+			fmt.Sprintf("%.4f", r.MAP),
+			fmt.Sprintf("%.4f", r.NDCG),
+			fmt.Sprintf("%.4f", r.HitRate),
+			fmt.Sprintf("%.4f", r.Coverage),
+			fmt.Sprintf("%.2f", r.Seconds),
+		})
+	}
+	w.Flush()
+}
+
+func saveReportJSON(path string, out Output) {
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		log.Fatal("Error codificando informe: ", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Fatal("Error escribiendo ", path, ": ", err)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pcd-pc4/internal/env"
+	"pcd-pc4/internal/eval"
+	"pcd-pc4/pkg/network"
+)
+
+// ---------------------------------------------------------
+// Evaluación distribuida: con EVAL_NODES los modelos KNN (user
+// e item) se evalúan en los nodos. Cada nodo recibe el split de
+// train completo y los usuarios de test se reparten en lotes; el
+// coordinador agrega las métricas por usuario que devuelven.
+// ---------------------------------------------------------
+
+const (
+	evalLoadChunk   = 2000             // usuarios de train por mensaje eval_load
+	evalNodeWorkers = 2                // lotes en vuelo por nodo
+	evalCallTimeout = 10 * time.Minute // plazo por lote o parte del split
+
+	// Un nodo con MAX_INFLIGHT tareas en curso rechaza el lote; se
+	// reintenta con espera creciente antes de darlo por caído
+	evalOverloadRetries = 10
+	evalOverloadBackoff = 500 * time.Millisecond
+)
+
+type remoteEval struct {
+	pool  *network.Pool
+	nodes []string
+	split string
+	train map[string]map[string]float64
+	batch int
+
+	loadMu sync.Mutex // una recarga a la vez
+}
+
+// newRemoteEval prepara la conexión a los nodos de EVAL_NODES (nil si no
+// hay); codecs, firma y TLS se configuran como en el API.
+func newRemoteEval(splitID string, train map[string]map[string]float64) *remoteEval {
+	var nodes []string
+	for _, addr := range strings.Split(os.Getenv("EVAL_NODES"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			nodes = append(nodes, addr)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	pool := network.NewPool()
+
+	codecs, err := network.ParseCodecs(os.Getenv("NODE_CODECS"))
+	if err != nil {
+		log.Fatal("NODE_CODECS inválido: ", err)
+	}
+	pool.Codecs = codecs
+
+	secret, err := network.SecretFromEnv("NODE")
+	if err != nil {
+		log.Fatal("NODE_SECRET inválido: ", err)
+	}
+	pool.Auth = network.NewAuthenticator(secret)
+
+	tlsCfg, err := network.TLSFromEnv("NODE", false)
+	if err != nil {
+		log.Fatal("Configuración TLS inválida: ", err)
+	}
+	if tlsCfg != nil {
+		dialer := &tls.Dialer{Config: tlsCfg}
+		pool.Dial = dialer.DialContext
+	}
+
+	batch := env.Int("EVAL_BATCH", 50, 1)
+
+	return &remoteEval{
+		pool:  pool,
+		nodes: nodes,
+		split: splitID,
+		train: train,
+		batch: batch,
+	}
+}
+
+// remoteTask arma la configuración de la tarea para los modelos que los
+// nodos saben evaluar (KNN user-based e item-based)
+func remoteTask(rec eval.Recommender, opts eval.Options) (network.EvalTaskRequest, bool) {
+	task := network.EvalTaskRequest{N: opts.K, Relevance: opts.Relevance}
+
+	switch r := rec.(type) {
+	case *eval.UserKNN:
+		task.Mode = network.ModeUser
+		task.K = r.K
+		task.Similarity = r.Similarity
+		task.MinOverlap = r.Filter.MinOverlap
+		task.Significance = r.Filter.Significance
+		task.Shrinkage = r.Filter.Shrinkage
+		task.Prediction = r.Predict.Method
+		task.MinSupport = r.Predict.MinSupport
+	case *eval.ItemKNN:
+		task.Mode = network.ModeItem
+		task.K = r.K
+		task.Similarity = r.Similarity
+		task.MinOverlap = r.Filter.MinOverlap
+		task.Significance = r.Filter.Significance
+		task.Shrinkage = r.Filter.Shrinkage
+	default:
+		return task, false
+	}
+	return task, true
+}
+
+// ---------------------------------------------------------
+// Carga del split
+// ---------------------------------------------------------
+
+// loadAll envía el split a todos los nodos en paralelo; un nodo que no
+// lo recibe queda fuera de la evaluación.
+func (r *remoteEval) loadAll() error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	alive := []string{}
+
+	for _, addr := range r.nodes {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+
+			if err := r.load(addr); err != nil {
+				fmt.Println("Nodo", addr, "fuera de la evaluación:", err)
+				return
+			}
+			mu.Lock()
+			alive = append(alive, addr)
+			mu.Unlock()
+		}(addr)
+	}
+	wg.Wait()
+
+	if len(alive) == 0 {
+		return fmt.Errorf("ningún nodo recibió el split")
+	}
+	sort.Strings(alive)
+	r.nodes = alive
+
+	fmt.Println("Split", r.split, "cargado en", len(alive), "nodos")
+	return nil
+}
+
+func (r *remoteEval) load(addr string) error {
+	users := make([]string, 0, len(r.train))
+	for u := range r.train {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+
+	for start := 0; start < len(users); start += evalLoadChunk {
+		end := min(start+evalLoadChunk, len(users))
+
+		req := network.EvalLoadRequest{
+			Split: r.split,
+			Total: len(users),
+			Users: make(map[string]map[string]float64, end-start),
+		}
+		for _, u := range users[start:end] {
+			req.Users[u] = r.train[u]
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), evalCallTimeout)
+		_, err := r.pool.Call(ctx, addr, network.Envelope{Type: network.MsgEvalLoad, EvalLoad: &req})
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------
+// Reparto de usuarios de test
+// ---------------------------------------------------------
+
+// evaluate reparte los usuarios de test de split en lotes entre los
+// nodos. Un nodo que falla deja de recibir lotes y el suyo vuelve a la
+// cola; sólo se aborta si fallan todos.
+func (r *remoteEval) evaluate(name string, task network.EvalTaskRequest, split eval.Split, opts eval.Options) (eval.Report, error) {
+	start := time.Now()
+
+	users := eval.TestUsers(split.Test, opts)
+	var batches [][]string
+	for i := 0; i < len(users); i += r.batch {
+		batches = append(batches, users[i:min(i+r.batch, len(users))])
+	}
+
+	results := make([][]eval.UserResult, len(batches))
+
+	jobs := make(chan int, len(batches))
+	for i := range batches {
+		jobs <- i
+	}
+
+	var pending sync.WaitGroup
+	pending.Add(len(batches))
+	allDone := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(allDone)
+	}()
+
+	var workers sync.WaitGroup
+	for _, addr := range r.nodes {
+		for w := 0; w < evalNodeWorkers; w++ {
+			workers.Add(1)
+			go func(addr string) {
+				defer workers.Done()
+
+				for i := range jobs {
+					res, err := r.call(addr, task, batches[i], split.Test)
+					if err != nil {
+						fmt.Println("Nodo", addr, "falló evaluando un lote:", err)
+						jobs <- i
+						return
+					}
+					results[i] = res
+					pending.Done()
+				}
+			}(addr)
+		}
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+
+	select {
+	case <-allDone:
+		close(jobs)
+		<-workersDone
+	case <-workersDone:
+		select {
+		case <-allDone:
+		default:
+			return eval.Report{Model: name}, fmt.Errorf("todos los nodos fallaron")
+		}
+	}
+
+	var all []eval.UserResult
+	for _, res := range results {
+		all = append(all, res...)
+	}
+
+	rep := eval.Aggregate(name, all, eval.Catalog(split.Train))
+	rep.Seconds = time.Since(start).Seconds()
+	return rep, nil
+}
+
+// call evalúa un lote en addr; si el nodo perdió el split (reinicio) se
+// lo reenvía y se reintenta una vez, y si está saturado se espera.
+func (r *remoteEval) call(addr string, task network.EvalTaskRequest, users []string, test map[string]map[string]float64) ([]eval.UserResult, error) {
+	task.Split = r.split
+	task.Test = make(map[string]map[string]float64, len(users))
+	for _, u := range users {
+		task.Test[u] = test[u]
+	}
+
+	overloaded, reloaded := 0, false
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), evalCallTimeout)
+		reply, err := r.pool.Call(ctx, addr, network.Envelope{Type: network.MsgEvalTask, EvalTask: &task})
+		cancel()
+
+		var remote *network.RemoteError
+		if errors.As(err, &remote) && remote.Code == network.ErrCodeOverloaded && overloaded < evalOverloadRetries {
+			overloaded++
+			time.Sleep(time.Duration(overloaded) * evalOverloadBackoff)
+			continue
+		}
+		if !reloaded && errors.As(err, &remote) && remote.Code == network.ErrCodeShardNotLoaded {
+			reloaded = true
+			fmt.Println("Nodo", addr, "sin el split, reenviando...")
+
+			r.loadMu.Lock()
+			err = r.load(addr)
+			r.loadMu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if reply.EvalResult == nil {
+			return nil, fmt.Errorf("nodo %s respondió %q sin resultado", addr, reply.Type)
+		}
+		return reply.EvalResult.Results, nil
+	}
+}
@@ -1,80 +1,618 @@
-package main
-
-import (
-	"fmt"
-	"net"
-	"os"
-
-	"pcd-pc4/internal/knn"
-	"pcd-pc4/pkg/network"
-)
-
-func main() {
-	// Leer puerto desde variable de entorno para soportar múltiples nodos
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "9000" // valor por defecto
-	}
-
-	addr := ":" + port
-	fmt.Println("Nodo ML escuchando en", addr)
-
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
-		panic(err)
-	}
-	defer ln.Close()
-
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			continue
-		}
-		go handleConnection(conn)
-	}
-}
-
-func handleConnection(conn net.Conn) {
-	defer conn.Close()
-
-	var req network.TaskRequest
-	if err := network.Receive(conn, &req); err != nil {
-		fmt.Println("Error recibiendo:", err)
-		return
-	}
-
-	partial := computePartialNeighbors(req)
-
-	resp := network.TaskResponse{
-		PartialNeighbors: partial,
-	}
-
-	if err := network.Send(conn, resp); err != nil {
-		fmt.Println("Error enviando respuesta:", err)
-	}
-}
-
-func computePartialNeighbors(req network.TaskRequest) []network.NeighborResult {
-	targetRatings := req.UserChunk[req.TargetUser]
-	if targetRatings == nil {
-		return nil
-	}
-
-	results := []network.NeighborResult{}
-
-	for user, ratings := range req.UserChunk {
-		if user == req.TargetUser {
-			continue
-		}
-
-		sim := knn.CosineSimilarity(targetRatings, ratings)
-		if sim > 0 {
-			results = append(results, network.NeighborResult{
-				UserID:     user,
-				Similarity: sim,
-			})
-		}
-	}
-
-	return knn.TopK(results, req.K)
-}
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"pcd-pc4/internal/eval"
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/internal/mf"
+	"pcd-pc4/pkg/cluster"
+	"pcd-pc4/pkg/network"
+)
+
+const heartbeatInterval = 2 * time.Second
+
+var (
+	// Tareas de vecinos simultáneas; por encima el nodo responde overloaded
+	taskSlots = make(chan struct{}, 64)
+
+	// Codecs y firma que el nodo exige en el handshake
+	serverCfg network.ServerConfig
+)
+
+// Shards residentes en memoria (asignados por el API). Con replicación un
+// nodo mantiene varios shards, cada uno con su versión; kind indica si son
+// vectores de usuarios o de películas (item-based).
+type residentShard struct {
+	version int64
+	kind    string
+	users   map[string]map[string]float64
+	means   map[string]float64 // medias globales para el coseno ajustado
+
+	// Versión anterior al último update: el API la sigue enviando hasta
+	// que todas las réplicas confirman los vectores nuevos
+	previous int64
+	updated  bool
+}
+
+// Caché de vectores de películas calificadas (item-based) de otros
+// shards, con la versión que indicó el API; así una tarea sólo trae los
+// vectores que el nodo todavía no tiene.
+type cachedItem struct {
+	version int64
+	vector  map[string]float64
+}
+
+var (
+	itemCacheMu   sync.Mutex
+	itemCache     = map[string]cachedItem{}
+	itemCacheSize = 20000 // vectores (ITEM_CACHE_SIZE)
+)
+
+// accepts indica si el shard atiende tareas de esa versión
+func (sh residentShard) accepts(version int64) bool {
+	return version == sh.version || (sh.updated && version == sh.previous)
+}
+
+var (
+	shardMu sync.RWMutex
+	shards  = map[string]residentShard{}
+)
+
+func main() {
+	// Leer puerto desde variable de entorno para soportar múltiples nodos
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "9000" // valor por defecto
+	}
+
+	if v := os.Getenv("MAX_INFLIGHT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			panic("MAX_INFLIGHT inválido: " + v)
+		}
+		taskSlots = make(chan struct{}, n)
+	}
+
+	if v := os.Getenv("ITEM_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			panic("ITEM_CACHE_SIZE inválido: " + v)
+		}
+		itemCacheSize = n
+	}
+
+	codecs, err := network.ParseCodecs(os.Getenv("NODE_CODECS"))
+	if err != nil {
+		panic(err)
+	}
+	serverCfg.Codecs = codecs
+
+	// --------------------------------------------------
+	// Seguridad: secreto compartido (HMAC) y TLS mutuo, ambos opcionales
+	// --------------------------------------------------
+
+	secret, err := network.SecretFromEnv("NODE")
+	if err != nil {
+		panic(err)
+	}
+	serverCfg.Auth = network.NewAuthenticator(secret)
+
+	tlsCfg, err := network.TLSFromEnv("NODE", true)
+	if err != nil {
+		panic(err)
+	}
+
+	addr := ":" + port
+	fmt.Println("Nodo ML escuchando en", addr, "codecs", codecs, "TLS", tlsCfg != nil, "HMAC", serverCfg.Auth != nil)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	if tlsCfg != nil {
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+	defer ln.Close()
+
+	// --------------------------------------------------
+	// Registro en el API + heartbeats
+	// --------------------------------------------------
+
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		apiURL = "http://pcd-pc4_api:8080"
+	}
+
+	// Dirección con la que el API nos contacta (nombre de contenedor)
+	advertised := os.Getenv("NODE_ADDR")
+	if advertised == "" {
+		host, _ := os.Hostname()
+		advertised = host + ":" + port
+	}
+
+	agent := &cluster.Agent{APIURL: apiURL, Addr: advertised, Auth: serverCfg.Auth}
+
+	stop := make(chan struct{})
+	go func() {
+		agent.Join(heartbeatInterval)
+		agent.Heartbeats(heartbeatInterval, stop)
+	}()
+
+	// Salida ordenada: avisar al API para que nos saque del scheduling
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+
+		close(stop)
+		if err := agent.Leave(); err != nil {
+			fmt.Println("Error al salir del cluster:", err)
+		}
+		os.Exit(0)
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			continue
+		}
+		go handleConnection(conn)
+	}
+}
+
+func handleConnection(conn net.Conn) {
+	// Conexión persistente: el API multiplexa varias peticiones en ella
+	if err := network.Serve(conn, serverCfg, handleMessage); err != nil && err != io.EOF {
+		fmt.Println("Conexión cerrada:", err)
+	}
+}
+
+func handleMessage(msg network.Envelope) network.Envelope {
+	switch {
+	case msg.Type == network.MsgAssign && msg.Assign != nil:
+		resp := assignShards(*msg.Assign)
+		return network.Envelope{Type: network.MsgAssignResult, AssignResult: &resp}
+
+	case msg.Type == network.MsgLoadShard && msg.Load != nil:
+		resp := loadShard(*msg.Load)
+		return network.Envelope{Type: network.MsgLoadResult, LoadResult: &resp}
+
+	case msg.Type == network.MsgUpdate && msg.Update != nil:
+		return updateShard(*msg.Update)
+
+	case msg.Type == network.MsgALSStep && msg.ALS != nil:
+		return handleALSStep(*msg.ALS)
+
+	case msg.Type == network.MsgEvalLoad && msg.EvalLoad != nil:
+		resp := loadEvalSplit(*msg.EvalLoad)
+		return network.Envelope{Type: network.MsgEvalLoaded, EvalLoaded: &resp}
+
+	case msg.Type == network.MsgEvalTask && msg.EvalTask != nil:
+		return withTaskSlot(func() network.Envelope { return handleEvalTask(*msg.EvalTask) })
+
+	case msg.Type == network.MsgTask && msg.Task != nil:
+		return withTaskSlot(func() network.Envelope { return handleTask(*msg.Task) })
+
+	default:
+		fmt.Println("Mensaje desconocido:", msg.Type)
+		return network.ErrorReply(network.ErrCodeBadRequest, "mensaje desconocido %q", msg.Type)
+	}
+}
+
+// withTaskSlot ejecuta una tarea (vecinos o evaluación) dentro del
+// límite de MAX_INFLIGHT. Rechaza en vez de encolar: el API prueba otra
+// réplica y cmd/evaluar reintenta más tarde.
+func withTaskSlot(task func() network.Envelope) network.Envelope {
+	select {
+	case taskSlots <- struct{}{}:
+		defer func() { <-taskSlots }()
+	default:
+		return network.ErrorReply(network.ErrCodeOverloaded, "%d tareas en curso", cap(taskSlots))
+	}
+	return task()
+}
+
+// -----------------------------------------------------------
+// Shards: el API fija la asignación y envía sólo lo que falta
+// -----------------------------------------------------------
+
+func assignShards(req network.AssignRequest) network.AssignResponse {
+	shardMu.Lock()
+	defer shardMu.Unlock()
+
+	for id, sh := range shards {
+		if v, ok := req.Shards[id]; !ok || v != sh.version {
+			delete(shards, id)
+		}
+	}
+
+	missing := []string{}
+	for id := range req.Shards {
+		if _, ok := shards[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	fmt.Println("Asignación recibida:", len(req.Shards), "shards,", len(missing), "por cargar")
+
+	return network.AssignResponse{Missing: missing}
+}
+
+func loadShard(req network.LoadShardRequest) network.LoadShardResponse {
+	shardMu.Lock()
+	shards[req.Shard] = residentShard{version: req.Version, kind: shardKind(req.Kind), users: req.Users, means: req.Means}
+	shardMu.Unlock()
+
+	fmt.Println("Shard", req.Shard, shardKind(req.Kind), "cargado con", len(req.Users), "vectores")
+
+	return network.LoadShardResponse{Users: len(req.Users)}
+}
+
+// updateShard aplica vectores y medias actualizados por ratings nuevos.
+// Los mapas del shard se copian en vez de modificarse porque un paso ALS
+// puede estar recorriéndolos sin lock. La versión anterior se sigue
+// aceptando hasta el próximo update o carga.
+func updateShard(req network.UpdateRequest) network.Envelope {
+	shardMu.Lock()
+	defer shardMu.Unlock()
+
+	sh, ok := shards[req.Shard]
+	if ok && sh.version == req.NewVersion {
+		// Reintento de un update ya aplicado
+		return network.Envelope{Type: network.MsgUpdateResult, UpdateResult: &network.UpdateResponse{Users: len(sh.users)}}
+	}
+	if !ok || sh.version != req.Version {
+		return network.ErrorReply(network.ErrCodeShardNotLoaded, "shard %s v%d", req.Shard, req.Version)
+	}
+
+	users := make(map[string]map[string]float64, len(sh.users)+len(req.Vectors))
+	for id, vec := range sh.users {
+		users[id] = vec
+	}
+	for id, vec := range req.Vectors {
+		users[id] = vec
+	}
+	sh.users = users
+
+	if len(req.Means) > 0 {
+		means := make(map[string]float64, len(sh.means)+len(req.Means))
+		for id, m := range sh.means {
+			means[id] = m
+		}
+		for id, m := range req.Means {
+			means[id] = m
+		}
+		sh.means = means
+	}
+
+	sh.previous, sh.updated = sh.version, true
+	sh.version = req.NewVersion
+	shards[req.Shard] = sh
+
+	fmt.Println("Shard", req.Shard, "actualizado:", len(req.Vectors), "vectores,", len(req.Means), "medias")
+
+	return network.Envelope{Type: network.MsgUpdateResult, UpdateResult: &network.UpdateResponse{Users: len(users)}}
+}
+
+func handleTask(req network.TaskRequest) network.Envelope {
+	if len(req.TargetRatings) == 0 {
+		return network.ErrorReply(network.ErrCodeUnknownUser, "usuario %s sin ratings", req.TargetUser)
+	}
+
+	// Los mapas del shard no se modifican (updateShard los copia): el
+	// recorrido va sin lock para no frenar cargas y updates
+	shardMu.RLock()
+	sh, ok := shards[req.Shard]
+	shardMu.RUnlock()
+
+	if !ok || !sh.accepts(req.Version) {
+		return network.ErrorReply(network.ErrCodeShardNotLoaded, "shard %s v%d", req.Shard, req.Version)
+	}
+	if sh.kind != shardKind(req.Mode) {
+		return network.ErrorReply(network.ErrCodeBadRequest, "tarea %s sobre shard %s de tipo %s", shardKind(req.Mode), req.Shard, sh.kind)
+	}
+
+	sim, err := knn.NewSimilarity(req.Similarity, sh.means)
+	if err != nil {
+		return network.ErrorReply(network.ErrCodeBadRequest, "%v", err)
+	}
+
+	filter := knn.NeighborFilter{
+		MinOverlap:   req.MinOverlap,
+		Significance: req.Significance,
+		Shrinkage:    req.Shrinkage,
+	}
+
+	var resp network.TaskResponse
+	if sh.kind == network.ModeItem {
+		rated, missing := resolveRatedItems(req, sh)
+		if len(missing) > 0 {
+			resp.MissingItems = missing
+			return network.Envelope{Type: network.MsgTaskResult, TaskResult: &resp}
+		}
+		resp.Predictions = computeItemPredictions(req, rated, sh.users, sim, filter)
+	} else {
+		resp.PartialNeighbors = computePartialNeighbors(req, sh.users, sim, filter)
+	}
+	return network.Envelope{Type: network.MsgTaskResult, TaskResult: &resp}
+}
+
+// shardKind normaliza el tipo de shard/tarea (vacío = user-based)
+func shardKind(kind string) string {
+	if kind == "" {
+		return network.ModeUser
+	}
+	return kind
+}
+
+// computePartialNeighbors: similitud con cada usuario del shard, ajustada
+// por el número de co-calificados, y top K parcial.
+func computePartialNeighbors(req network.TaskRequest, users map[string]map[string]float64, sim knn.Similarity, filter knn.NeighborFilter) []network.NeighborResult {
+	results := []network.NeighborResult{}
+
+	for user, ratings := range users {
+		if user == req.TargetUser {
+			continue
+		}
+
+		s, ok := filter.Apply(sim.Compute(req.TargetRatings, ratings), knn.Overlap(req.TargetRatings, ratings))
+		if ok && s > 0 {
+			results = append(results, network.NeighborResult{
+				UserID:     user,
+				Similarity: s,
+			})
+		}
+	}
+
+	return knn.TopK(results, req.K)
+}
+
+// resolveRatedItems arma los vectores de las películas que el usuario
+// calificó: los de la tarea (que se guardan en caché), los del propio
+// shard si está en la versión vigente y los de la caché con la versión
+// pedida. Devuelve las películas que faltan para que el API las envíe.
+func resolveRatedItems(req network.TaskRequest, sh residentShard) (map[string]map[string]float64, []string) {
+	if req.ItemVersions == nil {
+		// El API envió todos los vectores
+		return req.RatedItems, nil
+	}
+
+	itemCacheMu.Lock()
+	defer itemCacheMu.Unlock()
+
+	rated := make(map[string]map[string]float64, len(req.ItemVersions))
+	var missing []string
+
+	for movie, version := range req.ItemVersions {
+		if vec, ok := req.RatedItems[movie]; ok {
+			rated[movie] = vec
+			cacheItem(movie, version, vec)
+			continue
+		}
+		if vec, ok := sh.users[movie]; ok && req.Version == sh.version {
+			rated[movie] = vec
+			continue
+		}
+		if c, ok := itemCache[movie]; ok && c.version == version {
+			rated[movie] = c.vector
+			continue
+		}
+		missing = append(missing, movie)
+	}
+
+	sort.Strings(missing)
+	return rated, missing
+}
+
+// cacheItem guarda un vector; al llenarse la caché se descartan entradas
+// al azar (orden de recorrido del mapa). Requiere itemCacheMu.
+func cacheItem(movie string, version int64, vec map[string]float64) {
+	if itemCacheSize == 0 {
+		return
+	}
+	for id := range itemCache {
+		if len(itemCache) < itemCacheSize {
+			break
+		}
+		delete(itemCache, id)
+	}
+	itemCache[movie] = cachedItem{version: version, vector: vec}
+}
+
+// computeItemPredictions puntúa las películas del shard que el usuario no
+// vio y devuelve las N mejores; cada película vive en un solo shard, así
+// que el API obtiene el top N global uniendo los parciales.
+func computeItemPredictions(req network.TaskRequest, rated, items map[string]map[string]float64, sim knn.Similarity, filter knn.NeighborFilter) []network.ItemScore {
+	recs := knn.PredictItemBased(req.TargetRatings, rated, items, req.K, sim, filter)
+	recs = knn.TopNRecommendations(recs, req.N)
+
+	results := make([]network.ItemScore, 0, len(recs))
+	for _, r := range recs {
+		results = append(results, network.ItemScore{MovieID: r.MovieID, Predicted: r.Predicted})
+	}
+	return results
+}
+
+// -----------------------------------------------------------
+// Entrenamiento ALS distribuido: un paso sobre un shard
+// -----------------------------------------------------------
+
+func handleALSStep(req network.ALSRequest) network.Envelope {
+	// Un paso puede tardar: no bloquear asignaciones/cargas mientras tanto
+	shardMu.RLock()
+	sh, ok := shards[req.Shard]
+	shardMu.RUnlock()
+
+	if !ok || !sh.accepts(req.Version) {
+		return network.ErrorReply(network.ErrCodeShardNotLoaded, "shard %s v%d", req.Shard, req.Version)
+	}
+	if sh.kind != shardKind(req.Side) {
+		return network.ErrorReply(network.ErrCodeBadRequest, "paso ALS %s sobre shard %s de tipo %s", req.Side, req.Shard, sh.kind)
+	}
+
+	resp := network.ALSResponse{Vectors: make(map[string][]float64, len(sh.users))}
+
+	for id, ratings := range sh.users {
+		vec, ok := mf.SolveALS(ratings, req.Fixed, req.GlobalMean, req.Reg)
+		if !ok {
+			continue
+		}
+		resp.Vectors[id] = vec
+
+		sq, n := mf.ALSError(ratings, vec, req.Fixed, req.GlobalMean)
+		resp.SquaredError += sq
+		resp.Ratings += n
+	}
+
+	fmt.Println("Paso ALS", req.Side, "sobre", req.Shard, ":", len(resp.Vectors), "vectores")
+
+	return network.Envelope{Type: network.MsgALSResult, ALSResult: &resp}
+}
+
+// -----------------------------------------------------------
+// Evaluación offline distribuida: el coordinador (cmd/evaluar)
+// envía el split de train y reparte lotes de usuarios de test
+// -----------------------------------------------------------
+
+// Configuración del KNN evaluado; los recomendadores ya entrenados se
+// reutilizan entre lotes con la misma configuración
+type evalConfig struct {
+	mode         string
+	k            int
+	similarity   string
+	minOverlap   int
+	significance int
+	shrinkage    float64
+	prediction   string
+	minSupport   int
+}
+
+// Modelo ajustado con el split de una configuración; lo ajusta la
+// primera tarea que lo pide, fuera de evalMu, y las demás lo esperan
+type evalModel struct {
+	once sync.Once
+	rec  eval.Recommender
+	err  error
+}
+
+var (
+	evalMu    sync.Mutex
+	evalSplit string
+	evalTotal int
+	evalTrain map[string]map[string]float64
+	evalRecs  map[evalConfig]*evalModel
+)
+
+func loadEvalSplit(req network.EvalLoadRequest) network.EvalLoadResponse {
+	evalMu.Lock()
+	defer evalMu.Unlock()
+
+	// Split nuevo: descartar el anterior (las tareas en curso conservan
+	// su referencia al mapa viejo)
+	if req.Split != evalSplit {
+		evalSplit = req.Split
+		evalTrain = make(map[string]map[string]float64, req.Total)
+		evalRecs = map[evalConfig]*evalModel{}
+	}
+	// Split ya completo: los modelos se ajustan leyendo evalTrain sin
+	// lock, así que un reenvío del mismo split no lo toca
+	if len(evalTrain) == evalTotal && evalTotal == req.Total {
+		return network.EvalLoadResponse{Users: len(evalTrain)}
+	}
+	evalTotal = req.Total
+
+	for user, ratings := range req.Users {
+		evalTrain[user] = ratings
+	}
+
+	if len(evalTrain) == evalTotal {
+		fmt.Println("Split de evaluación", evalSplit, "cargado con", evalTotal, "usuarios")
+	}
+
+	return network.EvalLoadResponse{Users: len(evalTrain)}
+}
+
+func handleEvalTask(req network.EvalTaskRequest) network.Envelope {
+	cfg := evalConfig{
+		mode:         shardKind(req.Mode),
+		k:            req.K,
+		similarity:   req.Similarity,
+		minOverlap:   req.MinOverlap,
+		significance: req.Significance,
+		shrinkage:    req.Shrinkage,
+		prediction:   req.Prediction,
+		minSupport:   req.MinSupport,
+	}
+
+	evalMu.Lock()
+	if req.Split != evalSplit || len(evalTrain) != evalTotal {
+		evalMu.Unlock()
+		return network.ErrorReply(network.ErrCodeShardNotLoaded, "split de evaluación %s", req.Split)
+	}
+
+	model, ok := evalRecs[cfg]
+	if !ok {
+		model = &evalModel{}
+		evalRecs[cfg] = model
+	}
+	train := evalTrain
+	evalMu.Unlock()
+
+	// Ajustar puede tardar: sin evalMu, otras configuraciones y cargas
+	// del split siguen atendiéndose
+	model.once.Do(func() {
+		model.rec, model.err = newEvalRecommender(cfg)
+		if model.err == nil {
+			model.err = model.rec.Fit(train)
+		}
+	})
+	if model.err != nil {
+		return network.ErrorReply(network.ErrCodeBadRequest, "%v", model.err)
+	}
+	rec := model.rec
+
+	users := make([]string, 0, len(req.Test))
+	for u := range req.Test {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+
+	opts := eval.Options{K: req.N, Relevance: req.Relevance, Workers: runtime.NumCPU()}
+	resp := network.EvalTaskResponse{Results: eval.ScoreUsers(rec, req.Test, users, opts)}
+
+	return network.Envelope{Type: network.MsgEvalResult, EvalResult: &resp}
+}
+
+func newEvalRecommender(cfg evalConfig) (eval.Recommender, error) {
+	filter := knn.NeighborFilter{
+		MinOverlap:   cfg.minOverlap,
+		Significance: cfg.significance,
+		Shrinkage:    cfg.shrinkage,
+	}
+
+	if cfg.mode == network.ModeItem {
+		return &eval.ItemKNN{K: cfg.k, Similarity: cfg.similarity, Filter: filter}, nil
+	}
+
+	if err := knn.CheckPredictMethod(cfg.prediction); err != nil {
+		return nil, err
+	}
+	return &eval.UserKNN{
+		K:          cfg.k,
+		Similarity: cfg.similarity,
+		Filter:     filter,
+		Predict:    knn.PredictOptions{Method: cfg.prediction, MinSupport: cfg.minSupport},
+	}, nil
+}
@@ -0,0 +1,332 @@
+package bpr
+
+import (
+	"encoding/csv"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"pcd-pc4/internal/knn"
+)
+
+// ---------------------------------------------------------
+// Bayesian Personalized Ranking con factores latentes:
+//   x(u,i) = b_i + p_u · q_i
+// Sólo importa el orden: una película con interacción debe
+// puntuar más que una sin interacción para el mismo usuario.
+// ---------------------------------------------------------
+
+type Model struct {
+	Factors int
+
+	Users map[string]int
+	Items map[string]int
+
+	ItemBias []float64
+	P        [][]float64 // factores de usuarios
+	Q        [][]float64 // factores de películas
+}
+
+type Config struct {
+	Factors      int
+	Epochs       int
+	LearningRate float64
+	Reg          float64
+	InitStdDev   float64
+	Seed         int64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Factors:      50,
+		Epochs:       30,
+		LearningRate: 0.05,
+		Reg:          0.01,
+		InitStdDev:   0.1,
+		Seed:         42,
+	}
+}
+
+// ---------------------------------------------------------
+// Ingesta de interacciones implícitas (vistas, clics, ...)
+// ---------------------------------------------------------
+
+// Interactions: usuario -> película -> número de interacciones. Cualquier
+// interacción cuenta como señal positiva.
+type Interactions map[string]map[string]float64
+
+func (in Interactions) add(user, movie string) {
+	if _, ok := in[user]; !ok {
+		in[user] = make(map[string]float64)
+	}
+	in[user][movie]++
+}
+
+// LoadInteractions lee un CSV con cabecera que contenga userId y movieId
+// (el resto de columnas, p. ej. event o timestamp, se ignoran).
+func LoadInteractions(path string) (Interactions, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s sin cabecera: %w", path, err)
+	}
+
+	userCol, movieCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "userid", "user_id":
+			userCol = i
+		case "movieid", "movie_id", "itemid", "item_id":
+			movieCol = i
+		}
+	}
+	if userCol < 0 || movieCol < 0 {
+		return nil, fmt.Errorf("%s debe tener columnas userId y movieId", path)
+	}
+
+	in := Interactions{}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) <= userCol || len(rec) <= movieCol {
+			continue
+		}
+		in.add(rec[userCol], rec[movieCol])
+	}
+	return in, nil
+}
+
+// FromRatings convierte ratings explícitos en interacciones: cada rating
+// mayor o igual a minRating es una señal positiva.
+func FromRatings(ratings map[string]map[string]float64, minRating float64) Interactions {
+	in := Interactions{}
+	for user, rs := range ratings {
+		for movie, r := range rs {
+			if r >= minRating {
+				in.add(user, movie)
+			}
+		}
+	}
+	return in
+}
+
+// ---------------------------------------------------------
+// Entrenamiento por SGD con muestreo de negativos
+// ---------------------------------------------------------
+
+// Train recorre en cada época tantas tripletas (u, i, j) como
+// interacciones haya: i es una película con interacción de u y j una
+// elegida al azar sin interacción. progress recibe la pérdida media.
+func Train(in Interactions, cfg Config, progress func(epoch int, loss float64)) *Model {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	m := &Model{
+		Factors: cfg.Factors,
+		Users:   make(map[string]int),
+		Items:   make(map[string]int),
+	}
+
+	users := make([]string, 0, len(in))
+	itemSet := map[string]bool{}
+	for u, movies := range in {
+		users = append(users, u)
+		for i := range movies {
+			itemSet[i] = true
+		}
+	}
+	items := make([]string, 0, len(itemSet))
+	for i := range itemSet {
+		items = append(items, i)
+	}
+	sort.Strings(users)
+	sort.Strings(items)
+
+	for idx, u := range users {
+		m.Users[u] = idx
+	}
+	for idx, i := range items {
+		m.Items[i] = idx
+	}
+
+	m.ItemBias = make([]float64, len(items))
+	m.P = randomMatrix(len(users), cfg.Factors, cfg.InitStdDev, rng)
+	m.Q = randomMatrix(len(items), cfg.Factors, cfg.InitStdDev, rng)
+
+	// Positivos por usuario (índices) para muestrear y descartar negativos
+	positives := make([][]int, len(users))
+	seen := make([]map[int]bool, len(users))
+	total := 0
+	for _, u := range users {
+		ui := m.Users[u]
+		seen[ui] = make(map[int]bool, len(in[u]))
+		for i := range in[u] {
+			positives[ui] = append(positives[ui], m.Items[i])
+			seen[ui][m.Items[i]] = true
+		}
+		sort.Ints(positives[ui])
+		total += len(positives[ui])
+	}
+
+	if len(items) < 2 || total == 0 {
+		return m
+	}
+
+	lr, reg := cfg.LearningRate, cfg.Reg
+
+	for epoch := 1; epoch <= cfg.Epochs; epoch++ {
+		var loss float64
+
+		for s := 0; s < total; s++ {
+			u := rng.Intn(len(users))
+			if len(positives[u]) == 0 || len(positives[u]) == len(items) {
+				continue
+			}
+			i := positives[u][rng.Intn(len(positives[u]))]
+
+			j := rng.Intn(len(items))
+			for seen[u][j] {
+				j = rng.Intn(len(items))
+			}
+
+			pu, qi, qj := m.P[u], m.Q[i], m.Q[j]
+
+			x := m.ItemBias[i] - m.ItemBias[j]
+			for f := range pu {
+				x += pu[f] * (qi[f] - qj[f])
+			}
+
+			// d/dx ln σ(x) = σ(-x)
+			g := sigmoid(-x)
+			loss += -math.Log(sigmoid(x) + 1e-12)
+
+			m.ItemBias[i] += lr * (g - reg*m.ItemBias[i])
+			m.ItemBias[j] += lr * (-g - reg*m.ItemBias[j])
+
+			for f := range pu {
+				puf, qif, qjf := pu[f], qi[f], qj[f]
+				pu[f] += lr * (g*(qif-qjf) - reg*puf)
+				qi[f] += lr * (g*puf - reg*qif)
+				qj[f] += lr * (-g*puf - reg*qjf)
+			}
+		}
+
+		if progress != nil {
+			progress(epoch, loss/float64(total))
+		}
+	}
+
+	return m
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func randomMatrix(rows, cols int, stdDev float64, rng *rand.Rand) [][]float64 {
+	mat := make([][]float64, rows)
+	for r := range mat {
+		mat[r] = make([]float64, cols)
+		for c := range mat[r] {
+			mat[r][c] = rng.NormFloat64() * stdDev
+		}
+	}
+	return mat
+}
+
+// ---------------------------------------------------------
+// Recomendación (puntuación de ranking, no un rating)
+// ---------------------------------------------------------
+
+func (m *Model) scoreIndex(u, i int) float64 {
+	x := m.ItemBias[i]
+	qi := m.Q[i]
+	for f, v := range m.P[u] {
+		x += v * qi[f]
+	}
+	return x
+}
+
+// Score devuelve la puntuación de ranking; false si el modelo no conoce
+// al usuario o la película.
+func (m *Model) Score(user, item string) (float64, bool) {
+	u, uok := m.Users[user]
+	i, iok := m.Items[item]
+	if !uok || !iok {
+		return 0, false
+	}
+	return m.scoreIndex(u, i), true
+}
+
+// Recommend ordena las películas sin interacción (ni rating) del usuario
+func (m *Model) Recommend(user string, seen map[string]float64, n int) ([]knn.Recommended, error) {
+	u, ok := m.Users[user]
+	if !ok {
+		return nil, fmt.Errorf("usuario %s no está en el modelo", user)
+	}
+
+	recs := make([]knn.Recommended, 0, len(m.Items))
+	for item, i := range m.Items {
+		if _, done := seen[item]; done {
+			continue
+		}
+		recs = append(recs, knn.Recommended{MovieID: item, Predicted: m.scoreIndex(u, i)})
+	}
+
+	return knn.TopNRecommendations(recs, n), nil
+}
+
+// ---------------------------------------------------------
+// Persistencia (gob)
+// ---------------------------------------------------------
+
+func (m *Model) Save(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func Load(path string) (*Model, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m Model
+	if err := gob.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("modelo %s inválido: %w", path, err)
+	}
+	return &m, nil
+}
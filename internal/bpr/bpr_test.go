@@ -0,0 +1,123 @@
+package bpr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Dos grupos de gustos: los usuarios 1-4 ven a y b, los 5-8 ven c y d;
+// cada usuario, además, una película del otro grupo
+func toyInteractions() Interactions {
+	in := Interactions{}
+	for u := 1; u <= 8; u++ {
+		user := fmt.Sprint(u)
+		if u <= 4 {
+			in.add(user, "a")
+			in.add(user, "b")
+		} else {
+			in.add(user, "c")
+			in.add(user, "d")
+		}
+	}
+	in.add("1", "e")
+	in.add("5", "f")
+	return in
+}
+
+func TestTrainRanksPositivesFirst(t *testing.T) {
+	in := toyInteractions()
+	cfg := Config{Factors: 4, Epochs: 200, LearningRate: 0.05, Reg: 0.01, InitStdDev: 0.1, Seed: 3}
+
+	var first, last float64
+	m := Train(in, cfg, func(epoch int, loss float64) {
+		if epoch == 1 {
+			first = loss
+		}
+		last = loss
+	})
+	if last >= first {
+		t.Errorf("la pérdida no baja: %v -> %v", first, last)
+	}
+
+	groups := map[string][]string{"1": {"a", "b"}, "6": {"c", "d"}}
+	negatives := map[string][]string{"1": {"c", "d", "f"}, "6": {"a", "b", "e"}}
+	for user, positives := range groups {
+		for _, i := range positives {
+			for _, j := range negatives[user] {
+				si, _ := m.Score(user, i)
+				sj, _ := m.Score(user, j)
+				if si <= sj {
+					t.Errorf("usuario %s: %s (%v) no supera a %s (%v)", user, i, si, j, sj)
+				}
+			}
+		}
+	}
+
+	// Recommend no repite lo visto y empieza por el grupo del usuario
+	recs, err := m.Recommend("2", in["2"], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recs {
+		if r.MovieID == "a" || r.MovieID == "b" {
+			t.Errorf("Recommend repite %s: %v", r.MovieID, recs)
+		}
+	}
+
+	if again := Train(in, cfg, nil); !reflect.DeepEqual(again, m) {
+		t.Error("Train no es reproducible con el mismo seed")
+	}
+}
+
+func TestScoreUnknown(t *testing.T) {
+	m := Train(toyInteractions(), Config{Factors: 2, Epochs: 1, LearningRate: 0.05, Seed: 1}, nil)
+
+	if _, ok := m.Score("99", "a"); ok {
+		t.Error("Score de un usuario desconocido devolvió ok")
+	}
+	if _, ok := m.Score("1", "zz"); ok {
+		t.Error("Score de una película desconocida devolvió ok")
+	}
+	if _, err := m.Recommend("99", nil, 5); err == nil {
+		t.Error("Recommend de un usuario desconocido sin error")
+	}
+}
+
+func TestFromRatings(t *testing.T) {
+	got := FromRatings(map[string]map[string]float64{
+		"1": {"a": 5, "b": 3.5, "c": 4},
+		"2": {"a": 2},
+	}, 4)
+
+	want := Interactions{"1": {"a": 1, "c": 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromRatings = %v, want %v", got, want)
+	}
+}
+
+func TestLoadInteractions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interactions.csv")
+	data := "event,movieId,userId,timestamp\nview,10,1,100\nclick,10,1,101\nview,20,2,102\nview,30\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadInteractions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Interactions{"1": {"10": 2}, "2": {"20": 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadInteractions = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("user,item\n1,2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadInteractions(path); err == nil {
+		t.Error("LoadInteractions sin columnas userId/movieId no devolvió error")
+	}
+}
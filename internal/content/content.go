@@ -0,0 +1,222 @@
+package content
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"strings"
+
+	"pcd-pc4/internal/knn"
+)
+
+// ---------------------------------------------------------
+// Recomendador basado en contenido: perfiles TF-IDF de las
+// películas a partir de sus géneros y de los tags de usuarios
+// ---------------------------------------------------------
+
+// Prefijos para que un género y un tag con el mismo texto sean términos
+// distintos
+const (
+	genreTerm = "genre:"
+	tagTerm   = "tag:"
+)
+
+type Model struct {
+	IDF     map[string]float64            // término -> idf
+	Vectors map[string]map[string]float64 // película -> término -> peso (norma 1)
+}
+
+// ---------------------------------------------------------
+// Carga de movies.csv y tags.csv (salida de internal/limpieza)
+// ---------------------------------------------------------
+
+// LoadMovieGenres lee los géneros ("Action|Comedy") de movies.csv, en
+// minúsculas
+func LoadMovieGenres(path string) map[string][]string {
+	genres := make(map[string][]string)
+
+	for _, rec := range readCSV(path) {
+		if len(rec) < 3 {
+			continue
+		}
+		for _, g := range strings.Split(rec[2], "|") {
+			g = strings.ToLower(strings.TrimSpace(g))
+			if g == "" || g == "(no genres listed)" {
+				continue
+			}
+			genres[rec[0]] = append(genres[rec[0]], g)
+		}
+	}
+	return genres
+}
+
+// LoadMovieTerms arma los términos de cada película a partir de sus géneros
+func LoadMovieTerms(path string) map[string]map[string]float64 {
+	terms := make(map[string]map[string]float64)
+
+	for movie, genres := range LoadMovieGenres(path) {
+		for _, g := range genres {
+			addTerm(terms, movie, genreTerm+g, 1)
+		}
+	}
+	return terms
+}
+
+// AddTags suma a los términos de cada película los tags de tags.csv
+// (UserID, MovieID, Tag, Timestamp); cuenta cuántas veces se aplicó cada uno.
+func AddTags(terms map[string]map[string]float64, path string) {
+	for _, rec := range readCSV(path) {
+		if len(rec) < 3 {
+			continue
+		}
+		tag := strings.ToLower(strings.TrimSpace(rec[2]))
+		if tag == "" {
+			continue
+		}
+		addTerm(terms, rec[1], tagTerm+tag, 1)
+	}
+}
+
+func addTerm(terms map[string]map[string]float64, movie, term string, n float64) {
+	if _, ok := terms[movie]; !ok {
+		terms[movie] = make(map[string]float64)
+	}
+	terms[movie][term] += n
+}
+
+func readCSV(path string) [][]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, _ := r.ReadAll()
+	if len(records) > 0 {
+		records = records[1:] // cabecera
+	}
+	return records
+}
+
+// ---------------------------------------------------------
+// TF-IDF
+// ---------------------------------------------------------
+
+// Build pondera cada término con tf sublineal (1 + log tf) por idf
+// (log N/df) y normaliza los vectores de las películas.
+func Build(terms map[string]map[string]float64) *Model {
+	df := make(map[string]float64)
+	for _, ts := range terms {
+		for t := range ts {
+			df[t]++
+		}
+	}
+
+	n := float64(len(terms))
+	m := &Model{
+		IDF:     make(map[string]float64, len(df)),
+		Vectors: make(map[string]map[string]float64, len(terms)),
+	}
+	for t, d := range df {
+		m.IDF[t] = math.Log(n / d)
+	}
+
+	for movie, ts := range terms {
+		vec := make(map[string]float64, len(ts))
+		for t, tf := range ts {
+			if w := (1 + math.Log(tf)) * m.IDF[t]; w > 0 {
+				vec[t] = w
+			}
+		}
+		if normalize(vec) {
+			m.Vectors[movie] = vec
+		}
+	}
+
+	return m
+}
+
+func normalize(vec map[string]float64) bool {
+	var norm float64
+	for _, w := range vec {
+		norm += w * w
+	}
+	if norm == 0 {
+		return false
+	}
+	norm = math.Sqrt(norm)
+	for t := range vec {
+		vec[t] /= norm
+	}
+	return true
+}
+
+// ---------------------------------------------------------
+// Perfil del usuario y ranking
+// ---------------------------------------------------------
+
+// UserProfile suma los vectores de las películas calificadas ponderados
+// por la desviación del rating respecto a la media del usuario (lo que
+// no le gustó resta); si todos sus ratings son iguales pesan por igual.
+func (m *Model) UserProfile(ratings map[string]float64) map[string]float64 {
+	var sum float64
+	for _, r := range ratings {
+		sum += r
+	}
+	mean := 0.0
+	if len(ratings) > 0 {
+		mean = sum / float64(len(ratings))
+	}
+
+	profile := make(map[string]float64)
+	uniform := true
+	for _, r := range ratings {
+		if r != mean {
+			uniform = false
+			break
+		}
+	}
+
+	for movie, r := range ratings {
+		weight := r - mean
+		if uniform {
+			weight = 1
+		}
+		for t, w := range m.Vectors[movie] {
+			profile[t] += weight * w
+		}
+	}
+
+	normalize(profile)
+	return profile
+}
+
+// Score es la similitud de coseno entre un perfil (normalizado) y una película
+func (m *Model) Score(profile map[string]float64, movie string) float64 {
+	var dot float64
+	for t, w := range m.Vectors[movie] {
+		dot += w * profile[t]
+	}
+	return dot
+}
+
+// Recommend ordena las películas no vistas por similitud con el perfil
+func (m *Model) Recommend(profile, seen map[string]float64, n int) []knn.Recommended {
+	recs := []knn.Recommended{}
+	if len(profile) == 0 {
+		return recs
+	}
+
+	for movie := range m.Vectors {
+		if _, done := seen[movie]; done {
+			continue
+		}
+		if s := m.Score(profile, movie); s > 0 {
+			recs = append(recs, knn.Recommended{MovieID: movie, Predicted: s})
+		}
+	}
+
+	return knn.TopNRecommendations(recs, n)
+}
@@ -0,0 +1,126 @@
+package content
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Dos películas: "action" está en ambas (idf log(2/2) = 0, se descarta);
+// comedy, drama y el tag funny (aplicado dos veces) tienen idf log 2
+func twoMovies() map[string]map[string]float64 {
+	return map[string]map[string]float64{
+		"1": {"genre:action": 1, "genre:comedy": 1, "tag:funny": 2},
+		"2": {"genre:action": 1, "genre:drama": 1},
+	}
+}
+
+func TestBuildTFIDF(t *testing.T) {
+	m := Build(twoMovies())
+
+	ln2 := math.Log(2)
+	wantIDF := map[string]float64{"genre:action": 0, "genre:comedy": ln2, "genre:drama": ln2, "tag:funny": ln2}
+	for term, want := range wantIDF {
+		if math.Abs(m.IDF[term]-want) > 1e-12 {
+			t.Errorf("IDF[%s] = %v, want %v", term, m.IDF[term], want)
+		}
+	}
+
+	// película 1 antes de normalizar: comedy log 2, funny (1 + log 2)·log 2
+	funny := 1 + ln2
+	norm := math.Sqrt(1 + funny*funny)
+	want := map[string]map[string]float64{
+		"1": {"genre:comedy": 1 / norm, "tag:funny": funny / norm},
+		"2": {"genre:drama": 1},
+	}
+	if len(m.Vectors) != len(want) {
+		t.Fatalf("Vectors = %v", m.Vectors)
+	}
+	for movie, vec := range want {
+		if len(m.Vectors[movie]) != len(vec) {
+			t.Errorf("Vectors[%s] = %v, want %v", movie, m.Vectors[movie], vec)
+			continue
+		}
+		for term, w := range vec {
+			if math.Abs(m.Vectors[movie][term]-w) > 1e-12 {
+				t.Errorf("Vectors[%s][%s] = %v, want %v", movie, term, m.Vectors[movie][term], w)
+			}
+		}
+	}
+}
+
+func TestProfileScore(t *testing.T) {
+	m := Build(twoMovies())
+
+	tests := []struct {
+		name    string
+		ratings map[string]float64
+		want    map[string]float64 // película -> coseno con el perfil
+	}{
+		// un solo rating: el perfil es el vector de la película
+		{"una película", map[string]float64{"1": 5}, map[string]float64{"1": 1, "2": 0}},
+		// media 4: pesos +1 y -1 sobre vectores ortogonales
+		{"gusta y no gusta", map[string]float64{"1": 5, "2": 3}, map[string]float64{"1": 1 / math.Sqrt2, "2": -1 / math.Sqrt2}},
+		// ratings iguales pesan lo mismo
+		{"ratings iguales", map[string]float64{"1": 4, "2": 4}, map[string]float64{"1": 1 / math.Sqrt2, "2": 1 / math.Sqrt2}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			profile := m.UserProfile(tc.ratings)
+			for movie, want := range tc.want {
+				if got := m.Score(profile, movie); math.Abs(got-want) > 1e-12 {
+					t.Errorf("Score(%s) = %v, want %v", movie, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	terms := twoMovies()
+	terms["3"] = map[string]float64{"genre:comedy": 1}
+	m := Build(terms)
+
+	profile := m.UserProfile(map[string]float64{"1": 5})
+	recs := m.Recommend(profile, map[string]float64{"1": 5}, 10)
+
+	// con tres películas action ya pesa: 3 comparte comedy con 1 y 2
+	// comparte action, diluido por drama; 1 ya está vista
+	if len(recs) != 2 || recs[0].MovieID != "3" || recs[1].MovieID != "2" || recs[1].Predicted <= 0 {
+		t.Errorf("Recommend = %v", recs)
+	}
+
+	if recs := m.Recommend(map[string]float64{}, nil, 10); len(recs) != 0 {
+		t.Errorf("Recommend con perfil vacío = %v", recs)
+	}
+}
+
+func TestLoadTerms(t *testing.T) {
+	dir := t.TempDir()
+	movies := filepath.Join(dir, "movies.csv")
+	tags := filepath.Join(dir, "tags.csv")
+
+	writeFile(t, movies, "movieId,title,genres\n1,Toy Story (1995),Adventure|Comedy\n2,Nada,(no genres listed)\n")
+	writeFile(t, tags, "userId,movieId,tag,timestamp\n5,1,Funny,0\n6,1, funny ,0\n7,2,dark,0\n")
+
+	terms := LoadMovieTerms(movies)
+	AddTags(terms, tags)
+
+	want := map[string]map[string]float64{
+		"1": {"genre:adventure": 1, "genre:comedy": 1, "tag:funny": 2},
+		"2": {"tag:dark": 1},
+	}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("terms = %v, want %v", terms, want)
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,71 @@
+package env
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// Configuración de las herramientas de línea de comandos por
+// variables de entorno. Una sola regla: si la variable no está
+// se usa el valor por defecto; si no es un número o es menor
+// que min, el programa termina.
+// ---------------------------------------------------------
+
+func Int(name string, def, min int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min {
+		log.Fatalf("%s inválido (mínimo %d): %s", name, min, v)
+	}
+	return n
+}
+
+func Float(name string, def, min float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < min {
+		log.Fatalf("%s inválido (mínimo %g): %s", name, min, v)
+	}
+	return f
+}
+
+// List: lista separada por comas (def si la variable no está)
+func List(name, def string) []string {
+	v := os.Getenv(name)
+	if v == "" {
+		v = def
+	}
+
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		log.Fatal(name, " vacío")
+	}
+	return list
+}
+
+// Ints: lista de enteros separados por comas, cada uno al menos min
+func Ints(name, def string, min int) []int {
+	var list []int
+	for _, item := range List(name, def) {
+		n, err := strconv.Atoi(item)
+		if err != nil || n < min {
+			log.Fatalf("%s inválido (mínimo %d): %s", name, min, item)
+		}
+		list = append(list, n)
+	}
+	return list
+}
@@ -0,0 +1,106 @@
+package eval
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------
+// Evaluación: entrena con train y mide a los usuarios de test
+// en paralelo
+// ---------------------------------------------------------
+
+type Options struct {
+	K         int     // tamaño del top a evaluar
+	Relevance float64 // rating mínimo de una película relevante
+	Workers   int     // goroutines que puntúan usuarios
+	MaxUsers  int     // 0 = todos los usuarios de test
+	Seed      int64   // muestra de usuarios si hay MaxUsers
+}
+
+// TestUsers devuelve los usuarios de test ordenados o, si hay MaxUsers,
+// una muestra reproducible de ellos.
+func TestUsers(test map[string]map[string]float64, opts Options) []string {
+	users := make([]string, 0, len(test))
+	for u := range test {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+
+	if opts.MaxUsers > 0 && len(users) > opts.MaxUsers {
+		rng := rand.New(rand.NewSource(opts.Seed))
+		rng.Shuffle(len(users), func(i, j int) { users[i], users[j] = users[j], users[i] })
+		users = users[:opts.MaxUsers]
+		sort.Strings(users)
+	}
+	return users
+}
+
+// ScoreUsers calcula las métricas de cada usuario con rec ya entrenado.
+// Los resultados quedan en el mismo orden que users.
+func ScoreUsers(rec Recommender, test map[string]map[string]float64, users []string, opts Options) []UserResult {
+	results := make([]UserResult, len(users))
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				user := users[i]
+				movies := make([]string, 0, len(test[user]))
+				for m := range test[user] {
+					movies = append(movies, m)
+				}
+
+				preds, top := rec.Score(user, movies, opts.K)
+				results[i] = UserMetrics(user, preds, top, test[user], opts.K, opts.Relevance)
+			}
+		}()
+	}
+
+	for i := range users {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// Catalog: número de películas distintas en train
+func Catalog(train map[string]map[string]float64) int {
+	movies := map[string]bool{}
+	for _, ratings := range train {
+		for m := range ratings {
+			movies[m] = true
+		}
+	}
+	return len(movies)
+}
+
+// Evaluate entrena rec con split.Train y agrega sus métricas sobre
+// split.Test. Seconds incluye entrenamiento y evaluación.
+func Evaluate(rec Recommender, split Split, opts Options) (Report, error) {
+	start := time.Now()
+
+	if err := rec.Fit(split.Train); err != nil {
+		return Report{Model: rec.Name()}, err
+	}
+
+	users := TestUsers(split.Test, opts)
+	results := ScoreUsers(rec, split.Test, users, opts)
+
+	rep := Aggregate(rec.Name(), results, Catalog(split.Train))
+	rep.Seconds = time.Since(start).Seconds()
+	return rep, nil
+}
@@ -0,0 +1,204 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/pkg/network"
+)
+
+// ---------------------------------------------------------
+// Métricas por usuario y agregadas
+// ---------------------------------------------------------
+
+// UserResult: métricas de un usuario de test. Es el mismo tipo que
+// devuelven los nodos en la evaluación distribuida.
+type UserResult = network.EvalUserResult
+
+// UserMetrics compara las predicciones y el top k de un usuario con sus
+// ratings de test; relevantes son los de rating >= relevance.
+func UserMetrics(user string, predictions map[string]float64, top []knn.Recommended, test map[string]float64, k int, relevance float64) UserResult {
+	res := UserResult{UserID: user}
+
+	for movie, r := range test {
+		p, ok := predictions[movie]
+		if !ok {
+			continue
+		}
+		res.SquaredError += (p - r) * (p - r)
+		res.AbsoluteError += math.Abs(p - r)
+		res.Predicted++
+	}
+
+	if len(top) > k {
+		top = top[:k]
+	}
+	for _, r := range top {
+		res.Items = append(res.Items, r.MovieID)
+	}
+
+	relevant := 0
+	for _, r := range test {
+		if r >= relevance {
+			relevant++
+		}
+	}
+	if relevant == 0 {
+		return res
+	}
+	res.Ranked = true
+
+	hits := 0
+	var dcg, sumPrecision float64
+	for i, r := range top {
+		if test[r.MovieID] >= relevance {
+			hits++
+			dcg += 1 / math.Log2(float64(i+2))
+			sumPrecision += float64(hits) / float64(i+1)
+		}
+	}
+
+	var idcg float64
+	for i := 0; i < relevant && i < k; i++ {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+
+	res.Precision = float64(hits) / float64(k)
+	res.Recall = float64(hits) / float64(relevant)
+	res.AP = sumPrecision / math.Min(float64(relevant), float64(k))
+	res.NDCG = dcg / idcg
+	res.Hit = hits > 0
+	return res
+}
+
+// Report: métricas agregadas de un recomendador
+type Report struct {
+	Model string `json:"model"`
+	Users int    `json:"users"`
+
+	// Error sobre los ratings de test con predicción (Predicted = 0 si
+	// el modelo sólo ordena, p. ej. BPR o contenido)
+	Predicted int     `json:"predicted"`
+	RMSE      float64 `json:"rmse"`
+	MAE       float64 `json:"mae"`
+
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	MAP       float64 `json:"map"`
+	NDCG      float64 `json:"ndcg"`
+	HitRate   float64 `json:"hit_rate"`
+	Coverage  float64 `json:"coverage"` // películas recomendadas / catálogo
+
+	Seconds float64 `json:"seconds"`
+}
+
+// Aggregate promedia los resultados por usuario; catalog es el número de
+// películas que el modelo podía recomendar.
+func Aggregate(model string, results []UserResult, catalog int) Report {
+	rep := Report{Model: model, Users: len(results)}
+
+	var sq, abs float64
+	predicted, ranked := 0, 0
+	items := map[string]bool{}
+
+	for _, r := range results {
+		sq += r.SquaredError
+		abs += r.AbsoluteError
+		predicted += r.Predicted
+
+		for _, m := range r.Items {
+			items[m] = true
+		}
+
+		if !r.Ranked {
+			continue
+		}
+		ranked++
+		rep.Precision += r.Precision
+		rep.Recall += r.Recall
+		rep.MAP += r.AP
+		rep.NDCG += r.NDCG
+		if r.Hit {
+			rep.HitRate++
+		}
+	}
+
+	rep.Predicted = predicted
+	if predicted > 0 {
+		rep.RMSE = math.Sqrt(sq / float64(predicted))
+		rep.MAE = abs / float64(predicted)
+	}
+
+	if ranked > 0 {
+		n := float64(ranked)
+		rep.Precision /= n
+		rep.Recall /= n
+		rep.MAP /= n
+		rep.NDCG /= n
+		rep.HitRate /= n
+	}
+
+	if catalog > 0 {
+		rep.Coverage = float64(len(items)) / float64(catalog)
+	}
+	return rep
+}
+
+// MeanReport promedia los informes de varias particiones (k-fold) del
+// mismo modelo; Users y Predicted se suman.
+func MeanReport(reports []Report) Report {
+	if len(reports) == 0 {
+		return Report{}
+	}
+
+	mean := Report{Model: reports[0].Model}
+	for _, r := range reports {
+		mean.Users += r.Users
+		mean.Predicted += r.Predicted
+		mean.RMSE += r.RMSE
+		mean.MAE += r.MAE
+		mean.Precision += r.Precision
+		mean.Recall += r.Recall
+		mean.MAP += r.MAP
+		mean.NDCG += r.NDCG
+		mean.HitRate += r.HitRate
+		mean.Coverage += r.Coverage
+		mean.Seconds += r.Seconds
+	}
+
+	n := float64(len(reports))
+	mean.RMSE /= n
+	mean.MAE /= n
+	mean.Precision /= n
+	mean.Recall /= n
+	mean.MAP /= n
+	mean.NDCG /= n
+	mean.HitRate /= n
+	mean.Coverage /= n
+	return mean
+}
+
+// Metric devuelve la métrica pedida de un informe y si un valor menor es
+// mejor (errores) o peor (ranking).
+func Metric(rep Report, name string) (value float64, lowerIsBetter bool, err error) {
+	switch name {
+	case "rmse":
+		return rep.RMSE, true, nil
+	case "mae":
+		return rep.MAE, true, nil
+	case "precision":
+		return rep.Precision, false, nil
+	case "recall":
+		return rep.Recall, false, nil
+	case "map":
+		return rep.MAP, false, nil
+	case "ndcg":
+		return rep.NDCG, false, nil
+	case "hit_rate":
+		return rep.HitRate, false, nil
+	case "coverage":
+		return rep.Coverage, false, nil
+	}
+	return 0, false, fmt.Errorf("métrica desconocida %q", name)
+}
@@ -0,0 +1,177 @@
+package eval
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"pcd-pc4/internal/knn"
+)
+
+func recommended(movies ...string) []knn.Recommended {
+	out := make([]knn.Recommended, len(movies))
+	for i, m := range movies {
+		out[i] = knn.Recommended{MovieID: m}
+	}
+	return out
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestUserMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		predictions map[string]float64
+		top         []knn.Recommended
+		test        map[string]float64
+		k           int
+		want        UserResult
+	}{
+		{
+			// relevantes a y c; aciertos en las posiciones 1 y 3 del top 5
+			// (z queda fuera del corte). DCG = 1 + 1/log2(4) = 1.5,
+			// IDCG = 1 + 1/log2(3), AP = (1/1 + 2/3) / 2
+			name:        "aciertos parciales",
+			predictions: map[string]float64{"a": 4, "b": 3.5, "d": 4, "x": 5},
+			top:         recommended("a", "x", "c", "b", "y", "z"),
+			test:        map[string]float64{"a": 5, "b": 3, "c": 4, "d": 2},
+			k:           5,
+			want: UserResult{
+				SquaredError: 1 + 0.25 + 4, AbsoluteError: 1 + 0.5 + 2, Predicted: 3,
+				Ranked: true, Precision: 0.4, Recall: 1, AP: 5.0 / 6, NDCG: 0.9197207891481876, Hit: true,
+				Items: []string{"a", "x", "c", "b", "y"},
+			},
+		},
+		{
+			// más relevantes que k: AP e IDCG se cortan en k
+			name: "top perfecto",
+			top:  recommended("b", "a"),
+			test: map[string]float64{"a": 5, "b": 4.5, "c": 4},
+			k:    2,
+			want: UserResult{
+				Ranked: true, Precision: 1, Recall: 2.0 / 3, AP: 1, NDCG: 1, Hit: true,
+				Items: []string{"b", "a"},
+			},
+		},
+		{
+			name: "sin aciertos",
+			top:  recommended("x", "y"),
+			test: map[string]float64{"a": 5},
+			k:    2,
+			want: UserResult{Ranked: true, Items: []string{"x", "y"}},
+		},
+		{
+			// sin relevantes sólo cuenta el error de predicción
+			name:        "sin relevantes",
+			predictions: map[string]float64{"a": 2, "b": 1},
+			top:         recommended("a"),
+			test:        map[string]float64{"a": 3, "b": 3.5},
+			k:           3,
+			want:        UserResult{SquaredError: 1 + 6.25, AbsoluteError: 1 + 2.5, Predicted: 2, Items: []string{"a"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := UserMetrics("u", tc.predictions, tc.top, tc.test, tc.k, 4)
+			tc.want.UserID = "u"
+
+			if got.Predicted != tc.want.Predicted || got.Ranked != tc.want.Ranked || got.Hit != tc.want.Hit ||
+				!reflect.DeepEqual(got.Items, tc.want.Items) {
+				t.Errorf("got %+v\nwant %+v", got, tc.want)
+			}
+			for _, m := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"squared_error", got.SquaredError, tc.want.SquaredError},
+				{"absolute_error", got.AbsoluteError, tc.want.AbsoluteError},
+				{"precision", got.Precision, tc.want.Precision},
+				{"recall", got.Recall, tc.want.Recall},
+				{"ap", got.AP, tc.want.AP},
+				{"ndcg", got.NDCG, tc.want.NDCG},
+			} {
+				if !near(m.got, m.want) {
+					t.Errorf("%s = %v, want %v", m.name, m.got, m.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	results := []UserResult{
+		{SquaredError: 5, AbsoluteError: 3, Predicted: 2, Ranked: true,
+			Precision: 0.4, Recall: 1, AP: 0.5, NDCG: 0.8, Hit: true, Items: []string{"a", "b"}},
+		// sin relevantes: suma al error y a la cobertura, no al ranking
+		{SquaredError: 4, AbsoluteError: 2, Predicted: 2, Items: []string{"b", "c"}},
+		{Ranked: true},
+	}
+
+	got := Aggregate("knn", results, 10)
+	want := Report{
+		Model: "knn", Users: 3, Predicted: 4,
+		RMSE: 1.5, MAE: 1.25, // sqrt(9/4), 5/4
+		Precision: 0.2, Recall: 0.5, MAP: 0.25, NDCG: 0.4, HitRate: 0.5,
+		Coverage: 0.3, // {a, b, c} de 10
+	}
+	if !reportsNear(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	if got := Aggregate("bpr", nil, 0); !reportsNear(got, Report{Model: "bpr"}) {
+		t.Errorf("sin usuarios: %+v", got)
+	}
+}
+
+func TestMeanReport(t *testing.T) {
+	reports := []Report{
+		{Model: "knn", Users: 100, Predicted: 900, RMSE: 0.9, MAE: 0.7, Precision: 0.1, NDCG: 0.2, Seconds: 2},
+		{Model: "knn", Users: 110, Predicted: 1000, RMSE: 1.1, MAE: 0.8, Precision: 0.3, NDCG: 0.4, Seconds: 3},
+	}
+	want := Report{Model: "knn", Users: 210, Predicted: 1900, RMSE: 1, MAE: 0.75, Precision: 0.2, NDCG: 0.3, Seconds: 5}
+
+	if got := MeanReport(reports); !reportsNear(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestMetric(t *testing.T) {
+	rep := Report{RMSE: 0.9, Precision: 0.25}
+	tests := []struct {
+		name  string
+		value float64
+		lower bool
+	}{
+		{"rmse", 0.9, true},
+		{"precision", 0.25, false},
+		{"coverage", 0, false},
+	}
+	for _, tc := range tests {
+		value, lower, err := Metric(rep, tc.name)
+		if err != nil || value != tc.value || lower != tc.lower {
+			t.Errorf("Metric(%q) = %v, %v, %v", tc.name, value, lower, err)
+		}
+	}
+
+	if _, _, err := Metric(rep, "f1"); err == nil {
+		t.Error("Metric(f1) sin error")
+	}
+}
+
+func reportsNear(a, b Report) bool {
+	if a.Model != b.Model || a.Users != b.Users || a.Predicted != b.Predicted {
+		return false
+	}
+	pairs := [][2]float64{
+		{a.RMSE, b.RMSE}, {a.MAE, b.MAE}, {a.Precision, b.Precision}, {a.Recall, b.Recall},
+		{a.MAP, b.MAP}, {a.NDCG, b.NDCG}, {a.HitRate, b.HitRate}, {a.Coverage, b.Coverage},
+		{a.Seconds, b.Seconds},
+	}
+	for _, p := range pairs {
+		if !near(p[0], p[1]) {
+			return false
+		}
+	}
+	return true
+}
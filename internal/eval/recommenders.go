@@ -0,0 +1,286 @@
+package eval
+
+import (
+	"fmt"
+
+	"pcd-pc4/internal/bpr"
+	"pcd-pc4/internal/content"
+	"pcd-pc4/internal/hybrid"
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/internal/mf"
+	"pcd-pc4/internal/popularity"
+	"pcd-pc4/pkg/network"
+)
+
+// ---------------------------------------------------------
+// Recomendadores evaluables: se entrenan con el split de train
+// y puntúan a un usuario de test
+// ---------------------------------------------------------
+
+type Recommender interface {
+	Name() string
+	Fit(train map[string]map[string]float64) error
+
+	// Score devuelve la predicción de rating de las películas pedidas (nil
+	// si el modelo sólo ordena) y las n mejores películas no vistas.
+	Score(user string, movies []string, n int) (map[string]float64, []knn.Recommended)
+}
+
+// Después de Fit, Score debe poder llamarse desde varias goroutines.
+
+// ---------------------------------------------------------
+// KNN user-based (mismo cálculo que los nodos, en local)
+// ---------------------------------------------------------
+
+type UserKNN struct {
+	K          int
+	Similarity string
+	Filter     knn.NeighborFilter
+	Predict    knn.PredictOptions
+
+	train     map[string]map[string]float64
+	itemMeans map[string]float64
+}
+
+func (r *UserKNN) Name() string { return "user" }
+
+func (r *UserKNN) Fit(train map[string]map[string]float64) error {
+	if _, err := knn.NewSimilarity(r.Similarity, nil); err != nil {
+		return err
+	}
+	r.train = train
+	r.itemMeans = knn.MeanRatings(knn.TransposeRatings(train))
+	return nil
+}
+
+// Neighbors: top K vecinos del usuario en train
+func (r *UserKNN) Neighbors(user string) []network.NeighborResult {
+	sim, _ := knn.NewSimilarity(r.Similarity, r.itemMeans)
+	target := r.train[user]
+
+	neighbors := []network.NeighborResult{}
+	if len(target) == 0 {
+		return neighbors
+	}
+
+	for other, ratings := range r.train {
+		if other == user {
+			continue
+		}
+		s, ok := r.Filter.Apply(sim.Compute(target, ratings), knn.Overlap(target, ratings))
+		if ok && s > 0 {
+			neighbors = append(neighbors, network.NeighborResult{UserID: other, Similarity: s})
+		}
+	}
+	return knn.TopK(neighbors, r.K)
+}
+
+func (r *UserKNN) Score(user string, movies []string, n int) (map[string]float64, []knn.Recommended) {
+	recs := knn.PredictRatingsWith(user, r.train, r.Neighbors(user), r.Predict)
+	return pick(recs, movies), knn.TopNRecommendations(recs, n)
+}
+
+// pick toma de recs las predicciones de las películas pedidas
+func pick(recs []knn.Recommended, movies []string) map[string]float64 {
+	all := make(map[string]float64, len(recs))
+	for _, rec := range recs {
+		all[rec.MovieID] = rec.Predicted
+	}
+
+	preds := make(map[string]float64, len(movies))
+	for _, m := range movies {
+		if p, ok := all[m]; ok {
+			preds[m] = p
+		}
+	}
+	return preds
+}
+
+// ---------------------------------------------------------
+// KNN item-based
+// ---------------------------------------------------------
+
+type ItemKNN struct {
+	K          int
+	Similarity string
+	Filter     knn.NeighborFilter
+
+	train     map[string]map[string]float64
+	items     map[string]map[string]float64
+	userMeans map[string]float64
+}
+
+func (r *ItemKNN) Name() string { return "item" }
+
+func (r *ItemKNN) Fit(train map[string]map[string]float64) error {
+	if _, err := knn.NewSimilarity(r.Similarity, nil); err != nil {
+		return err
+	}
+	r.train = train
+	r.items = knn.TransposeRatings(train)
+	r.userMeans = knn.MeanRatings(train)
+	return nil
+}
+
+func (r *ItemKNN) Score(user string, movies []string, n int) (map[string]float64, []knn.Recommended) {
+	sim, _ := knn.NewSimilarity(r.Similarity, r.userMeans)
+	target := r.train[user]
+
+	rated := make(map[string]map[string]float64, len(target))
+	for m := range target {
+		rated[m] = r.items[m]
+	}
+
+	recs := knn.PredictItemBased(target, rated, r.items, r.K, sim, r.Filter)
+	return pick(recs, movies), knn.TopNRecommendations(recs, n)
+}
+
+// ---------------------------------------------------------
+// Factorización matricial (SGD)
+// ---------------------------------------------------------
+
+type MF struct {
+	Config mf.Config
+
+	train map[string]map[string]float64
+	model *mf.Model
+}
+
+func (r *MF) Name() string { return "mf" }
+
+func (r *MF) Fit(train map[string]map[string]float64) error {
+	r.train = train
+	r.model = mf.Train(train, r.Config, nil)
+	return nil
+}
+
+func (r *MF) Score(user string, movies []string, n int) (map[string]float64, []knn.Recommended) {
+	preds := make(map[string]float64, len(movies))
+	for _, m := range movies {
+		preds[m] = r.model.Predict(user, m)
+	}
+
+	top, _ := r.model.Recommend(user, r.train[user], n)
+	return preds, top
+}
+
+// ---------------------------------------------------------
+// BPR (ratings >= MinRating como interacciones)
+// ---------------------------------------------------------
+
+type BPR struct {
+	Config    bpr.Config
+	MinRating float64
+
+	train map[string]map[string]float64
+	model *bpr.Model
+}
+
+func (r *BPR) Name() string { return "bpr" }
+
+func (r *BPR) Fit(train map[string]map[string]float64) error {
+	r.train = train
+	r.model = bpr.Train(bpr.FromRatings(train, r.MinRating), r.Config, nil)
+	return nil
+}
+
+func (r *BPR) Score(user string, movies []string, n int) (map[string]float64, []knn.Recommended) {
+	top, _ := r.model.Recommend(user, r.train[user], n)
+	return nil, top
+}
+
+// ---------------------------------------------------------
+// Contenido (perfiles TF-IDF ya construidos)
+// ---------------------------------------------------------
+
+type Content struct {
+	Model *content.Model
+
+	train map[string]map[string]float64
+}
+
+func (r *Content) Name() string { return "content" }
+
+func (r *Content) Fit(train map[string]map[string]float64) error {
+	if r.Model == nil || len(r.Model.Vectors) == 0 {
+		return fmt.Errorf("modelo de contenido vacío")
+	}
+	r.train = train
+	return nil
+}
+
+func (r *Content) Score(user string, movies []string, n int) (map[string]float64, []knn.Recommended) {
+	ratings := r.train[user]
+	return nil, r.Model.Recommend(r.Model.UserProfile(ratings), ratings, n)
+}
+
+// ---------------------------------------------------------
+// Popularidad (promedio bayesiano, igual para todos)
+// ---------------------------------------------------------
+
+type Popularity struct {
+	MinVotes float64
+
+	train map[string]map[string]float64
+	model *popularity.Model
+}
+
+func (r *Popularity) Name() string { return "popularity" }
+
+func (r *Popularity) Fit(train map[string]map[string]float64) error {
+	r.train = train
+	r.model = popularity.Build(knn.TransposeRatings(train), r.MinVotes)
+	return nil
+}
+
+func (r *Popularity) Score(user string, movies []string, n int) (map[string]float64, []knn.Recommended) {
+	preds := make(map[string]float64, len(movies))
+	for _, m := range movies {
+		preds[m] = r.model.Score(m)
+	}
+	return preds, r.model.Top(n, r.train[user], nil)
+}
+
+// ---------------------------------------------------------
+// Híbrido: KNN user-based + contenido + popularidad
+// ---------------------------------------------------------
+
+type Hybrid struct {
+	KNN      *UserKNN
+	Content  *content.Model
+	MinVotes float64
+	Weights  hybrid.Weights
+	Strategy string
+
+	train map[string]map[string]float64
+	pop   *popularity.Model
+}
+
+func (r *Hybrid) Name() string { return "hybrid" }
+
+func (r *Hybrid) Fit(train map[string]map[string]float64) error {
+	if err := hybrid.CheckStrategy(r.Strategy); err != nil {
+		return err
+	}
+	if err := r.KNN.Fit(train); err != nil {
+		return err
+	}
+	r.train = train
+	r.pop = popularity.Build(knn.TransposeRatings(train), r.MinVotes)
+	return nil
+}
+
+func (r *Hybrid) Score(user string, movies []string, n int) (map[string]float64, []knn.Recommended) {
+	_, knnTop := r.KNN.Score(user, nil, hybrid.Pool)
+
+	scored, err := hybrid.Rank(knnTop, r.train[user], r.Content, r.pop, r.Weights, r.Strategy, n)
+	if err != nil {
+		return nil, nil
+	}
+
+	top := make([]knn.Recommended, 0, len(scored))
+	for _, s := range scored {
+		top = append(top, knn.Recommended{MovieID: s.MovieID, Predicted: s.Score})
+	}
+	return nil, top
+}
@@ -0,0 +1,131 @@
+package eval
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"pcd-pc4/internal/knn"
+)
+
+// ---------------------------------------------------------
+// Partición train/test de los ratings
+// ---------------------------------------------------------
+
+const (
+	SplitRandom    = "random"      // una fracción de todos los ratings al azar
+	SplitLeaveKOut = "leave_k_out" // k ratings al azar de cada usuario
+	SplitTimestamp = "timestamp"   // la fracción más reciente de los ratings
+)
+
+type SplitConfig struct {
+	Method       string  `json:"method"`
+	TestFraction float64 `json:"test_fraction"` // random y timestamp
+	LeaveK       int     `json:"leave_k"`       // leave_k_out
+	Seed         int64   `json:"seed"`
+}
+
+// Matrices usuario -> película -> rating
+type Split struct {
+	Train map[string]map[string]float64
+	Test  map[string]map[string]float64
+}
+
+// MakeSplit parte ratings según cfg. En leave_k_out sólo se retiran
+// ratings de usuarios con más de k, para que conserven historial.
+func MakeSplit(ratings []knn.Rating, cfg SplitConfig) (Split, error) {
+	// Orden determinista antes de barajar (mismo seed, mismo split)
+	list := append([]knn.Rating(nil), ratings...)
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].UserID != list[j].UserID {
+			return list[i].UserID < list[j].UserID
+		}
+		return list[i].MovieID < list[j].MovieID
+	})
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	var train, test []knn.Rating
+
+	switch cfg.Method {
+	case SplitRandom:
+		if cfg.TestFraction <= 0 || cfg.TestFraction >= 1 {
+			return Split{}, fmt.Errorf("fracción de test inválida: %g", cfg.TestFraction)
+		}
+		for _, r := range list {
+			if rng.Float64() < cfg.TestFraction {
+				test = append(test, r)
+			} else {
+				train = append(train, r)
+			}
+		}
+
+	case SplitLeaveKOut:
+		if cfg.LeaveK < 1 {
+			return Split{}, fmt.Errorf("k inválido para leave_k_out: %d", cfg.LeaveK)
+		}
+		for start := 0; start < len(list); {
+			end := start
+			for end < len(list) && list[end].UserID == list[start].UserID {
+				end++
+			}
+			user := list[start:end]
+
+			if len(user) <= cfg.LeaveK {
+				train = append(train, user...)
+			} else {
+				rng.Shuffle(len(user), func(i, j int) { user[i], user[j] = user[j], user[i] })
+				test = append(test, user[:cfg.LeaveK]...)
+				train = append(train, user[cfg.LeaveK:]...)
+			}
+			start = end
+		}
+
+	case SplitTimestamp:
+		if cfg.TestFraction <= 0 || cfg.TestFraction >= 1 {
+			return Split{}, fmt.Errorf("fracción de test inválida: %g", cfg.TestFraction)
+		}
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Timestamp < list[j].Timestamp })
+		cut := int(float64(len(list)) * (1 - cfg.TestFraction))
+		train, test = list[:cut], list[cut:]
+
+	default:
+		return Split{}, fmt.Errorf("método de split desconocido %q", cfg.Method)
+	}
+
+	return Split{Train: knn.RatingsMatrix(train), Test: knn.RatingsMatrix(test)}, nil
+}
+
+// KFold reparte los ratings al azar en k partes y devuelve k splits: en
+// el i-ésimo la parte i es test y el resto train.
+func KFold(ratings []knn.Rating, k int, seed int64) ([]Split, error) {
+	if k < 2 {
+		return nil, fmt.Errorf("k-fold necesita al menos 2 partes: %d", k)
+	}
+	if len(ratings) < k {
+		return nil, fmt.Errorf("%d ratings no alcanzan para %d partes", len(ratings), k)
+	}
+
+	list := append([]knn.Rating(nil), ratings...)
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].UserID != list[j].UserID {
+			return list[i].UserID < list[j].UserID
+		}
+		return list[i].MovieID < list[j].MovieID
+	})
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+
+	splits := make([]Split, k)
+	for fold := range splits {
+		var train, test []knn.Rating
+		for i, r := range list {
+			if i%k == fold {
+				test = append(test, r)
+			} else {
+				train = append(train, r)
+			}
+		}
+		splits[fold] = Split{Train: knn.RatingsMatrix(train), Test: knn.RatingsMatrix(test)}
+	}
+	return splits, nil
+}
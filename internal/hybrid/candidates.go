@@ -0,0 +1,66 @@
+package hybrid
+
+import (
+	"sort"
+
+	"pcd-pc4/internal/content"
+	"pcd-pc4/internal/knn"
+	"pcd-pc4/internal/popularity"
+)
+
+// ---------------------------------------------------------
+// Candidatos del híbrido KNN + contenido + popularidad: lo usan
+// el API (model=hybrid) y la evaluación offline, para que lo que
+// se mide sea lo mismo que se sirve
+// ---------------------------------------------------------
+
+// Pool: candidatos que aporta cada componente antes de combinar
+const Pool = 100
+
+// Candidates une el top del KNN (hasta Pool; vacío si el KNN no
+// respondió) con las mejores Pool películas de contenido y de
+// popularidad. Contenido y popularidad puntúan a todos los candidatos;
+// content puede ser nil. Devuelve los candidatos ordenados por id y las
+// puntuaciones escaladas de cada componente.
+func Candidates(knnTop []knn.Recommended, ratings map[string]float64, cm *content.Model, pop *popularity.Model) ([]string, map[string]map[string]float64) {
+	components := map[string]map[string]float64{
+		CompKNN:        {},
+		CompContent:    {},
+		CompPopularity: {},
+	}
+	candidates := map[string]bool{}
+
+	for _, r := range knnTop {
+		components[CompKNN][r.MovieID] = Scale(CompKNN, r.Predicted)
+		candidates[r.MovieID] = true
+	}
+
+	var profile map[string]float64
+	if cm != nil {
+		profile = cm.UserProfile(ratings)
+		for _, r := range cm.Recommend(profile, ratings, Pool) {
+			candidates[r.MovieID] = true
+		}
+	}
+	for _, r := range pop.Top(Pool, ratings, nil) {
+		candidates[r.MovieID] = true
+	}
+
+	list := make([]string, 0, len(candidates))
+	for movie := range candidates {
+		list = append(list, movie)
+		if len(profile) > 0 {
+			components[CompContent][movie] = Scale(CompContent, cm.Score(profile, movie))
+		}
+		components[CompPopularity][movie] = Scale(CompPopularity, pop.Score(movie))
+	}
+	sort.Strings(list)
+
+	return list, components
+}
+
+// Rank arma los candidatos y los combina con Blend
+func Rank(knnTop []knn.Recommended, ratings map[string]float64, cm *content.Model, pop *popularity.Model, weights Weights, strategy string, n int) ([]Scored, error) {
+	list, components := Candidates(knnTop, ratings, cm, pop)
+	return Blend(list, components, weights, strategy, n)
+}
@@ -0,0 +1,320 @@
+package hybrid
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ---------------------------------------------------------
+// Ranker híbrido: combina las puntuaciones de varios modelos
+// (KNN, contenido, popularidad) sobre un mismo conjunto de
+// películas candidatas
+// ---------------------------------------------------------
+
+// Componentes conocidos
+const (
+	CompKNN        = "knn"        // predicción de knn.PredictRatings
+	CompContent    = "content"    // coseno perfil-película
+	CompPopularity = "popularity" // promedio bayesiano
+
+	// Cold start: recomendación del modelo pedido (cualquier escala)
+	CompPersonalized = "personalized"
+)
+
+// Estrategias de combinación
+const (
+	StrategyWeighted = "weighted" // suma ponderada de las puntuaciones escaladas
+	StrategyRank     = "rank"     // reciprocal rank fusion ponderado
+)
+
+// Constante de reciprocal rank fusion: 1 / (rrfK + posición)
+const rrfK = 60
+
+// Escala máxima de los ratings; KNN y popularidad se dividen por ella
+// para quedar en el mismo rango que el coseno del contenido
+const MaxRating = 5.0
+
+type Weights map[string]float64
+
+func DefaultWeights() Weights {
+	return Weights{
+		CompKNN:        0.6,
+		CompContent:    0.25,
+		CompPopularity: 0.15,
+	}
+}
+
+// Scored: película con su puntuación final y la de cada componente
+// (antes de ponderar), útil para depurar el ranking.
+type Scored struct {
+	MovieID    string             `json:"movie_id"`
+	Score      float64            `json:"score"`
+	Components map[string]float64 `json:"components"`
+}
+
+// CheckStrategy valida el nombre de una estrategia
+func CheckStrategy(strategy string) error {
+	switch strategy {
+	case StrategyWeighted, StrategyRank:
+		return nil
+	default:
+		return fmt.Errorf("estrategia híbrida desconocida %q", strategy)
+	}
+}
+
+// Scale lleva la puntuación de un componente a ~[0, 1]
+func Scale(component string, score float64) float64 {
+	switch component {
+	case CompKNN, CompPopularity:
+		return score / MaxRating
+	default:
+		return score
+	}
+}
+
+// ---------------------------------------------------------
+// Combinación
+// ---------------------------------------------------------
+
+// Blend combina components (componente -> película -> puntuación escalada)
+// sobre candidates y devuelve las n mejores. En weighted, a una película
+// sin puntuación de un componente se le asigna la media de ese componente
+// entre los candidatos; en rank simplemente no suma.
+func Blend(candidates []string, components map[string]map[string]float64, weights Weights, strategy string, n int) ([]Scored, error) {
+	if err := CheckStrategy(strategy); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fill := make(map[string]float64, len(names))
+	ranks := make(map[string]map[string]int, len(names))
+
+	for _, name := range names {
+		scores := components[name]
+
+		var sum float64
+		for _, s := range scores {
+			sum += s
+		}
+		if len(scores) > 0 {
+			fill[name] = sum / float64(len(scores))
+		}
+
+		ranks[name] = rankOf(scores)
+	}
+
+	result := make([]Scored, 0, len(candidates))
+	for _, movie := range candidates {
+		sc := Scored{MovieID: movie, Components: make(map[string]float64, len(names))}
+
+		for _, name := range names {
+			s, ok := components[name][movie]
+			if ok {
+				sc.Components[name] = s
+			}
+
+			w := weights[name]
+			switch strategy {
+			case StrategyWeighted:
+				if !ok {
+					s = fill[name]
+				}
+				sc.Score += w * s
+			case StrategyRank:
+				if ok {
+					sc.Score += w / float64(rrfK+ranks[name][movie])
+				}
+			}
+		}
+
+		result = append(result, sc)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].MovieID < result[j].MovieID
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result, nil
+}
+
+// rankOf: posición (desde 1) de cada película al ordenar por puntuación
+func rankOf(scores map[string]float64) map[string]int {
+	movies := make([]string, 0, len(scores))
+	for m := range scores {
+		movies = append(movies, m)
+	}
+	sort.Slice(movies, func(i, j int) bool {
+		if scores[movies[i]] != scores[movies[j]] {
+			return scores[movies[i]] > scores[movies[j]]
+		}
+		return movies[i] < movies[j]
+	})
+
+	ranks := make(map[string]int, len(movies))
+	for i, m := range movies {
+		ranks[m] = i + 1
+	}
+	return ranks
+}
+
+// ---------------------------------------------------------
+// Pesos aprendidos en un split de validación
+// ---------------------------------------------------------
+
+// Example: puntuaciones escaladas de cada componente para un par
+// (usuario, película) de validación y el rating real.
+type Example struct {
+	Components map[string]float64
+	Rating     float64
+}
+
+// Fitted: pesos aprendidos y cómo se obtuvieron
+type Fitted struct {
+	Weights  Weights `json:"weights"`
+	Examples int     `json:"examples"`
+	RMSE     float64 `json:"rmse"` // del ajuste lineal sobre validación
+}
+
+// Fit ajusta por mínimos cuadrados (con intercepto y una pequeña
+// regularización) rating ≈ b + Σ w_c · componente_c. Los pesos negativos
+// se anulan y el resto se normaliza para sumar 1: el orden del ranking
+// no cambia con la escala ni con el intercepto.
+func Fit(examples []Example, names []string) (Fitted, error) {
+	dim := len(names) + 1
+	if len(examples) < dim {
+		return Fitted{}, fmt.Errorf("se necesitan al menos %d ejemplos de validación", dim)
+	}
+
+	a := make([][]float64, dim)
+	for i := range a {
+		a[i] = make([]float64, dim+1) // matriz aumentada [XᵀX | Xᵀy]
+	}
+
+	x := make([]float64, dim)
+	for _, ex := range examples {
+		x[0] = 1
+		for i, name := range names {
+			x[i+1] = ex.Components[name]
+		}
+		for i := 0; i < dim; i++ {
+			for j := 0; j < dim; j++ {
+				a[i][j] += x[i] * x[j]
+			}
+			a[i][dim] += x[i] * ex.Rating
+		}
+	}
+	for i := 1; i < dim; i++ {
+		a[i][i] += 1e-3 * float64(len(examples))
+	}
+
+	coef, err := gaussSolve(a)
+	if err != nil {
+		return Fitted{}, err
+	}
+
+	var sq float64
+	for _, ex := range examples {
+		pred := coef[0]
+		for i, name := range names {
+			pred += coef[i+1] * ex.Components[name]
+		}
+		sq += (ex.Rating - pred) * (ex.Rating - pred)
+	}
+
+	weights := Weights{}
+	var total float64
+	for i, name := range names {
+		w := math.Max(coef[i+1], 0)
+		weights[name] = w
+		total += w
+	}
+	if total == 0 {
+		return Fitted{}, fmt.Errorf("ningún componente aporta al ajuste")
+	}
+	for name := range weights {
+		weights[name] /= total
+	}
+
+	return Fitted{
+		Weights:  weights,
+		Examples: len(examples),
+		RMSE:     math.Sqrt(sq / float64(len(examples))),
+	}, nil
+}
+
+// gaussSolve resuelve un sistema en forma de matriz aumentada con
+// eliminación gaussiana y pivoteo parcial.
+func gaussSolve(a [][]float64) ([]float64, error) {
+	n := len(a)
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, fmt.Errorf("sistema singular en la columna %d", col)
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for r := col + 1; r < n; r++ {
+			f := a[r][col] / a[col][col]
+			for c := col; c <= n; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+		}
+	}
+
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		s := a[r][n]
+		for c := r + 1; c < n; c++ {
+			s -= a[r][c] * x[c]
+		}
+		x[r] = s / a[r][r]
+	}
+	return x, nil
+}
+
+func (f Fitted) Save(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func LoadFitted(path string) (Fitted, error) {
+	var f Fitted
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("pesos %s inválidos: %w", path, err)
+	}
+	return f, nil
+}
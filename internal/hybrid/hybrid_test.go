@@ -0,0 +1,72 @@
+package hybrid
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGaussSolve(t *testing.T) {
+	tests := []struct {
+		name string
+		a    [][]float64 // sistema aumentado [A | b]
+		want []float64
+	}{
+		{
+			//  2x + y -  z =   8
+			// -3x - y + 2z = -11
+			// -2x + y + 2z =  -3
+			name: "3x3",
+			a: [][]float64{
+				{2, 1, -1, 8},
+				{-3, -1, 2, -11},
+				{-2, 1, 2, -3},
+			},
+			want: []float64{2, 3, -1},
+		},
+		{
+			// cero en la diagonal: sólo se resuelve intercambiando filas
+			name: "pivoteo",
+			a: [][]float64{
+				{0, 2, 1, 7},
+				{1, 1, 1, 6},
+				{2, 1, 0, 4},
+			},
+			want: []float64{1, 2, 3},
+		},
+		{
+			name: "1x1",
+			a:    [][]float64{{4, 2}},
+			want: []float64{0.5},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := gaussSolve(tc.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if math.Abs(got[i]-tc.want[i]) > 1e-9 {
+					t.Errorf("got %v, want %v", got, tc.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestGaussSolveSingular(t *testing.T) {
+	// la tercera fila es la suma de las dos primeras
+	a := [][]float64{
+		{1, 2, 3, 1},
+		{4, 5, 6, 2},
+		{5, 7, 9, 3},
+	}
+	if x, err := gaussSolve(a); err == nil {
+		t.Errorf("sistema singular resuelto: %v", x)
+	}
+}
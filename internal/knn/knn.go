@@ -1,209 +1,415 @@
-package knn
-
-import (
-	"encoding/csv"
-	"fmt"
-	"math"
-	"os"
-	"pcd-pc4/pkg/network"
-	"sort"
-	"strconv"
-)
-
-// ---------------------------------------------------------
-// Estructuras reutilizables por API, nodos ML y ejecutables
-// ---------------------------------------------------------
-
-type Rating struct {
-	UserID  string
-	MovieID string
-	Rating  float64
-}
-
-type Neighbor struct {
-	UserID     string
-	Similarity float64
-}
-
-type NeighborResult struct {
-	UserID     string
-	Similarity float64
-}
-
-type Recommended struct {
-	MovieID   string
-	Predicted float64
-}
-
-// ---------------------------------------------------------
-// Carga de datos (igual que antes, pero como funciones públicas)
-// ---------------------------------------------------------
-
-func LoadUserRatings(path string) map[string]map[string]float64 {
-	f, err := os.Open(path)
-	if err != nil {
-		fmt.Println("Error al abrir", path, ":", err)
-		return nil
-	}
-	defer f.Close()
-
-	r := csv.NewReader(f)
-	records, _ := r.ReadAll()
-	userRatings := make(map[string]map[string]float64)
-
-	for i, rec := range records {
-		if i == 0 || len(rec) < 3 {
-			continue
-		}
-		rating, err := strconv.ParseFloat(rec[2], 64)
-		if err != nil {
-			continue
-		}
-		user := rec[0]
-		movie := rec[1]
-		if _, ok := userRatings[user]; !ok {
-			userRatings[user] = make(map[string]float64)
-		}
-		userRatings[user][movie] = rating
-	}
-	return userRatings
-}
-
-func LoadMovieTitles(path string) map[string]string {
-	f, err := os.Open(path)
-	if err != nil {
-		return map[string]string{}
-	}
-	defer f.Close()
-
-	r := csv.NewReader(f)
-	records, _ := r.ReadAll()
-	m := make(map[string]string)
-
-	for i, rec := range records {
-		if i == 0 || len(rec) < 2 {
-			continue
-		}
-		m[rec[0]] = rec[1]
-	}
-	return m
-}
-
-// ---------------------------------------------------------
-// Similitud de Coseno  (usado por KNN local y nodos TCP)
-// ---------------------------------------------------------
-
-func CosineSimilarity(a, b map[string]float64) float64 {
-	var dot, normA, normB float64
-
-	for item, ra := range a {
-		if rb, ok := b[item]; ok {
-			dot += ra * rb
-		}
-		normA += ra * ra
-	}
-
-	for _, rb := range b {
-		normB += rb * rb
-	}
-
-	if normA == 0 || normB == 0 {
-		return 0
-	}
-
-	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
-}
-
-// ---------------------------------------------------------
-// Ordenamiento y selección de los K mejores vecinos
-// ---------------------------------------------------------
-
-func TopK(list []network.NeighborResult, k int) []network.NeighborResult {
-	sort.Slice(list, func(i, j int) bool {
-		return list[i].Similarity > list[j].Similarity
-	})
-	if len(list) > k {
-		return list[:k]
-	}
-	return list
-}
-
-// ---------------------------------------------------------
-// Generar recomendaciones finales a partir de vecinos K
-// ---------------------------------------------------------
-
-func PredictRatings(target string, ratings map[string]map[string]float64, neighbors []network.NeighborResult) []Recommended {
-	targetRatings := ratings[target]
-
-	scoreSum := make(map[string]float64)
-	weightSum := make(map[string]float64)
-
-	for _, nb := range neighbors {
-		ratings := ratings[nb.UserID]
-
-		for movie, r := range ratings {
-			if _, seen := targetRatings[movie]; seen {
-				continue
-			}
-			scoreSum[movie] += nb.Similarity * r
-			weightSum[movie] += math.Abs(nb.Similarity)
-		}
-	}
-
-	var recs []Recommended
-	for movie, s := range scoreSum {
-		w := weightSum[movie]
-		if w == 0 {
-			continue
-		}
-		recs = append(recs, Recommended{
-			MovieID:   movie,
-			Predicted: s / w,
-		})
-	}
-
-	return recs
-}
-
-// ---------------------------------------------------------
-// Top N recomendaciones ordenadas
-// ---------------------------------------------------------
-
-func TopNRecommendations(recs []Recommended, n int) []Recommended {
-	sort.Slice(recs, func(i, j int) bool {
-		return recs[i].Predicted > recs[j].Predicted
-	})
-	if len(recs) > n {
-		return recs[:n]
-	}
-	return recs
-}
-
-// ---------------------------------------------------------
-// Guardado de resultados en CSV (para ejecutables)
-// ---------------------------------------------------------
-
-func SaveNeighborsCSV(path, target string, neighbors []NeighborResult) {
-	f, _ := os.Create(path)
-	defer f.Close()
-	w := csv.NewWriter(f)
-	defer w.Flush()
-
-	w.Write([]string{"TargetUser", "NeighborUser", "Similarity"})
-	for _, n := range neighbors {
-		w.Write([]string{target, n.UserID, fmt.Sprintf("%.6f", n.Similarity)})
-	}
-}
-
-func SaveRecommendationsCSV(path, target string, recs []Recommended, titles map[string]string) {
-	f, _ := os.Create(path)
-	defer f.Close()
-	w := csv.NewWriter(f)
-	defer w.Flush()
-
-	w.Write([]string{"TargetUser", "MovieID", "Title", "PredictedRating"})
-	for _, r := range recs {
-		title := titles[r.MovieID]
-		w.Write([]string{target, r.MovieID, title, fmt.Sprintf("%.4f", r.Predicted)})
-	}
-}
+package knn
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"pcd-pc4/pkg/network"
+	"sort"
+	"strconv"
+)
+
+// ---------------------------------------------------------
+// Estructuras reutilizables por API, nodos ML y ejecutables
+// ---------------------------------------------------------
+
+type Rating struct {
+	UserID    string
+	MovieID   string
+	Rating    float64
+	Timestamp int64 // segundos Unix (0 si el CSV no lo trae)
+}
+
+type Neighbor struct {
+	UserID     string
+	Similarity float64
+}
+
+type NeighborResult struct {
+	UserID     string
+	Similarity float64
+}
+
+type Recommended struct {
+	MovieID   string
+	Predicted float64
+}
+
+// ---------------------------------------------------------
+// Carga de datos (igual que antes, pero como funciones públicas)
+// ---------------------------------------------------------
+
+func LoadUserRatings(path string) map[string]map[string]float64 {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Println("Error al abrir", path, ":", err)
+		return nil
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	records, _ := r.ReadAll()
+	userRatings := make(map[string]map[string]float64)
+
+	for i, rec := range records {
+		if i == 0 || len(rec) < 3 {
+			continue
+		}
+		rating, err := strconv.ParseFloat(rec[2], 64)
+		if err != nil {
+			continue
+		}
+		user := rec[0]
+		movie := rec[1]
+		if _, ok := userRatings[user]; !ok {
+			userRatings[user] = make(map[string]float64)
+		}
+		userRatings[user][movie] = rating
+	}
+	return userRatings
+}
+
+// LoadRatings lee ratings.csv como lista (UserID, MovieID, Rating[,
+// Timestamp]); la usan los ejecutables que necesitan el orden temporal.
+func LoadRatings(path string) []Rating {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Println("Error al abrir", path, ":", err)
+		return nil
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, _ := r.ReadAll()
+	ratings := make([]Rating, 0, len(records))
+
+	for i, rec := range records {
+		if i == 0 || len(rec) < 3 {
+			continue
+		}
+		value, err := strconv.ParseFloat(rec[2], 64)
+		if err != nil {
+			continue
+		}
+		rating := Rating{UserID: rec[0], MovieID: rec[1], Rating: value}
+		if len(rec) > 3 {
+			rating.Timestamp, _ = strconv.ParseInt(rec[3], 10, 64)
+		}
+		ratings = append(ratings, rating)
+	}
+	return ratings
+}
+
+// MergeRatings agrega newer a ratings: un par usuario-película que ya
+// estaba toma el rating y el timestamp de newer (el último gana)
+func MergeRatings(ratings, newer []Rating) []Rating {
+	type key struct{ user, movie string }
+	pos := make(map[key]int, len(ratings))
+	for i, r := range ratings {
+		pos[key{r.UserID, r.MovieID}] = i
+	}
+
+	for _, r := range newer {
+		k := key{r.UserID, r.MovieID}
+		if i, ok := pos[k]; ok {
+			ratings[i] = r
+			continue
+		}
+		pos[k] = len(ratings)
+		ratings = append(ratings, r)
+	}
+	return ratings
+}
+
+// RatingsMatrix agrupa una lista de ratings en usuario -> película -> rating
+func RatingsMatrix(ratings []Rating) map[string]map[string]float64 {
+	m := make(map[string]map[string]float64)
+	for _, r := range ratings {
+		if _, ok := m[r.UserID]; !ok {
+			m[r.UserID] = make(map[string]float64)
+		}
+		m[r.UserID][r.MovieID] = r.Rating
+	}
+	return m
+}
+
+func LoadMovieTitles(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return map[string]string{}
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	records, _ := r.ReadAll()
+	m := make(map[string]string)
+
+	for i, rec := range records {
+		if i == 0 || len(rec) < 2 {
+			continue
+		}
+		m[rec[0]] = rec[1]
+	}
+	return m
+}
+
+// ---------------------------------------------------------
+// Similitud de Coseno  (usado por KNN local y nodos TCP)
+// ---------------------------------------------------------
+
+func CosineSimilarity(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+
+	for item, ra := range a {
+		if rb, ok := b[item]; ok {
+			dot += ra * rb
+		}
+		normA += ra * ra
+	}
+
+	for _, rb := range b {
+		normB += rb * rb
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// ---------------------------------------------------------
+// Ordenamiento y selección de los K mejores vecinos
+// ---------------------------------------------------------
+
+func TopK(list []network.NeighborResult, k int) []network.NeighborResult {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Similarity > list[j].Similarity
+	})
+	if len(list) > k {
+		return list[:k]
+	}
+	return list
+}
+
+// ---------------------------------------------------------
+// Generar recomendaciones finales a partir de vecinos K
+// ---------------------------------------------------------
+
+// Métodos de predicción a partir de los vecinos
+const (
+	PredWeighted     = "weighted"      // promedio de ratings ponderado por similitud
+	PredMeanCentered = "mean_centered" // media del usuario + desviaciones de los vecinos
+	PredZScore       = "zscore"        // como mean_centered, normalizando por la desviación estándar
+)
+
+// Vecinos mínimos por defecto: con uno solo, la predicción copia su
+// rating y un 5.0 aislado llega al top
+const DefaultMinSupport = 3
+
+type PredictOptions struct {
+	Method     string // "" = PredWeighted
+	MinSupport int    // vecinos mínimos que calificaron la película (0 = sin mínimo)
+}
+
+// CheckPredictMethod valida el nombre de un método de predicción
+func CheckPredictMethod(method string) error {
+	switch method {
+	case "", PredWeighted, PredMeanCentered, PredZScore:
+		return nil
+	default:
+		return fmt.Errorf("método de predicción desconocido %q", method)
+	}
+}
+
+func PredictRatings(target string, ratings map[string]map[string]float64, neighbors []network.NeighborResult) []Recommended {
+	return PredictRatingsWith(target, ratings, neighbors, PredictOptions{})
+}
+
+// PredictRatingsWith predice con el método indicado. En mean_centered y
+// zscore cada vecino aporta su desviación respecto a su propia media (en
+// zscore además dividida por su desviación estándar), y el resultado se
+// traslada a la escala del usuario objetivo. Las películas calificadas por
+// menos de MinSupport vecinos se descartan.
+func PredictRatingsWith(target string, ratings map[string]map[string]float64, neighbors []network.NeighborResult, opts PredictOptions) []Recommended {
+	targetRatings := ratings[target]
+	targetMean, targetStd := meanStd(targetRatings)
+
+	scoreSum := make(map[string]float64)
+	weightSum := make(map[string]float64)
+	support := make(map[string]int)
+
+	for _, nb := range neighbors {
+		ratings := ratings[nb.UserID]
+		mean, std := meanStd(ratings)
+
+		for movie, r := range ratings {
+			if _, seen := targetRatings[movie]; seen {
+				continue
+			}
+
+			value := r
+			switch opts.Method {
+			case PredMeanCentered:
+				value = r - mean
+			case PredZScore:
+				if std == 0 {
+					value = 0
+				} else {
+					value = (r - mean) / std
+				}
+			}
+
+			scoreSum[movie] += nb.Similarity * value
+			weightSum[movie] += math.Abs(nb.Similarity)
+			support[movie]++
+		}
+	}
+
+	var recs []Recommended
+	for movie, s := range scoreSum {
+		w := weightSum[movie]
+		if w == 0 || support[movie] < opts.MinSupport {
+			continue
+		}
+
+		predicted := s / w
+		switch opts.Method {
+		case PredMeanCentered:
+			predicted = targetMean + predicted
+		case PredZScore:
+			predicted = targetMean + targetStd*predicted
+		}
+
+		recs = append(recs, Recommended{
+			MovieID:   movie,
+			Predicted: predicted,
+		})
+	}
+
+	return recs
+}
+
+// meanStd: media y desviación estándar (poblacional) de un vector de ratings
+func meanStd(ratings map[string]float64) (float64, float64) {
+	if len(ratings) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, r := range ratings {
+		sum += r
+	}
+	mean := sum / float64(len(ratings))
+
+	var sq float64
+	for _, r := range ratings {
+		sq += (r - mean) * (r - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(ratings)))
+}
+
+// ---------------------------------------------------------
+// Item-based: matriz transpuesta y predicción desde los
+// ratings del propio usuario
+// ---------------------------------------------------------
+
+// TransposeRatings convierte usuario -> película -> rating en
+// película -> usuario -> rating (vectores de ítems).
+func TransposeRatings(userRatings map[string]map[string]float64) map[string]map[string]float64 {
+	items := make(map[string]map[string]float64)
+
+	for user, ratings := range userRatings {
+		for movie, r := range ratings {
+			if _, ok := items[movie]; !ok {
+				items[movie] = make(map[string]float64)
+			}
+			items[movie][user] = r
+		}
+	}
+	return items
+}
+
+// PredictItemBased puntúa cada película candidata (no vista) con el
+// promedio de los ratings del usuario sobre sus k películas calificadas
+// más parecidas según sim (ajustada por filter). ratedItems son los
+// vectores de esas películas calificadas.
+func PredictItemBased(targetRatings map[string]float64, ratedItems, candidates map[string]map[string]float64, k int, sim Similarity, filter NeighborFilter) []Recommended {
+	var recs []Recommended
+
+	for movie, vec := range candidates {
+		if _, seen := targetRatings[movie]; seen {
+			continue
+		}
+
+		// Vecinos del ítem (UserID guarda aquí el id de la película)
+		similar := []network.NeighborResult{}
+		for rated, ratedVec := range ratedItems {
+			s, ok := filter.Apply(sim.Compute(vec, ratedVec), Overlap(vec, ratedVec))
+			if ok && s > 0 {
+				similar = append(similar, network.NeighborResult{UserID: rated, Similarity: s})
+			}
+		}
+
+		var score, weight float64
+		for _, nb := range TopK(similar, k) {
+			score += nb.Similarity * targetRatings[nb.UserID]
+			weight += nb.Similarity
+		}
+		if weight == 0 {
+			continue
+		}
+
+		recs = append(recs, Recommended{
+			MovieID:   movie,
+			Predicted: score / weight,
+		})
+	}
+
+	return recs
+}
+
+// ---------------------------------------------------------
+// Top N recomendaciones ordenadas
+// ---------------------------------------------------------
+
+func TopNRecommendations(recs []Recommended, n int) []Recommended {
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].Predicted > recs[j].Predicted
+	})
+	if len(recs) > n {
+		return recs[:n]
+	}
+	return recs
+}
+
+// ---------------------------------------------------------
+// Guardado de resultados en CSV (para ejecutables)
+// ---------------------------------------------------------
+
+func SaveNeighborsCSV(path, target string, neighbors []NeighborResult) {
+	f, _ := os.Create(path)
+	defer f.Close()
+	w := csv.NewWriter(f)
+	defer w.Flush()
+
+	w.Write([]string{"TargetUser", "NeighborUser", "Similarity"})
+	for _, n := range neighbors {
+		w.Write([]string{target, n.UserID, fmt.Sprintf("%.6f", n.Similarity)})
+	}
+}
+
+func SaveRecommendationsCSV(path, target string, recs []Recommended, titles map[string]string) {
+	f, _ := os.Create(path)
+	defer f.Close()
+	w := csv.NewWriter(f)
+	defer w.Flush()
+
+	w.Write([]string{"TargetUser", "MovieID", "Title", "PredictedRating"})
+	for _, r := range recs {
+		title := titles[r.MovieID]
+		w.Write([]string{target, r.MovieID, title, fmt.Sprintf("%.4f", r.Predicted)})
+	}
+}
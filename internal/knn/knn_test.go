@@ -0,0 +1,134 @@
+package knn
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"pcd-pc4/pkg/network"
+)
+
+// Usuario objetivo u: media 3, σ 2. Vecinos:
+//
+//	n1 (sim 1):   c=5 d=3       media 4, σ 1
+//	n2 (sim 0.5): c=1 e=5       media 3, σ 2
+//	n3 (sim 0.5): a=3 c=3 e=3   media 3, σ 0 (a ya la vio u)
+var predictRatings = map[string]map[string]float64{
+	"u":  {"a": 5, "b": 1},
+	"n1": {"c": 5, "d": 3},
+	"n2": {"c": 1, "e": 5},
+	"n3": {"a": 3, "c": 3, "e": 3},
+}
+
+var predictNeighbors = []network.NeighborResult{
+	{UserID: "n1", Similarity: 1},
+	{UserID: "n2", Similarity: 0.5},
+	{UserID: "n3", Similarity: 0.5},
+}
+
+func TestPredictRatingsWith(t *testing.T) {
+	tests := []struct {
+		name string
+		opts PredictOptions
+		want map[string]float64
+	}{
+		{
+			// c = (5 + 0.5·1 + 0.5·3) / 2, e = (0.5·5 + 0.5·3) / 1
+			name: "weighted",
+			opts: PredictOptions{Method: PredWeighted},
+			want: map[string]float64{"c": 3.5, "d": 3, "e": 4},
+		},
+		{
+			// c = 3 + (1·1 + 0.5·-2 + 0.5·0) / 2, d = 3 - 1, e = 3 + 0.5·2 / 1
+			name: "mean_centered",
+			opts: PredictOptions{Method: PredMeanCentered},
+			want: map[string]float64{"c": 3, "d": 2, "e": 4},
+		},
+		{
+			// desvíos / σ (n3 con σ 0 aporta 0): c = 3 + 2·(1 - 0.5) / 2,
+			// d = 3 + 2·-1, e = 3 + 2·(0.5·1) / 1
+			name: "zscore",
+			opts: PredictOptions{Method: PredZScore},
+			want: map[string]float64{"c": 3.5, "d": 1, "e": 4},
+		},
+		{
+			// soportes: c 3 vecinos, e 2, d 1
+			name: "min support 2",
+			opts: PredictOptions{Method: PredWeighted, MinSupport: 2},
+			want: map[string]float64{"c": 3.5, "e": 4},
+		},
+		{
+			name: "min support por defecto",
+			opts: PredictOptions{MinSupport: DefaultMinSupport},
+			want: map[string]float64{"c": 3.5},
+		},
+		{
+			name: "min support sin películas",
+			opts: PredictOptions{Method: PredMeanCentered, MinSupport: 4},
+			want: map[string]float64{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := PredictRatingsWith("u", predictRatings, predictNeighbors, tc.opts)
+			checkPredictions(t, got, tc.want)
+		})
+	}
+}
+
+// Con σ 0 en el usuario objetivo zscore predice su media, sin NaN
+func TestPredictZScoreConstantTarget(t *testing.T) {
+	ratings := map[string]map[string]float64{
+		"u":  {"a": 4, "b": 4},
+		"n1": {"c": 5, "d": 3},
+		"n2": {"c": 2, "d": 2},
+	}
+	neighbors := []network.NeighborResult{{UserID: "n1", Similarity: 0.9}, {UserID: "n2", Similarity: 0.4}}
+
+	got := PredictRatingsWith("u", ratings, neighbors, PredictOptions{Method: PredZScore})
+	checkPredictions(t, got, map[string]float64{"c": 4, "d": 4})
+}
+
+// PredictRatings mantiene el promedio ponderado sin mínimo de vecinos
+func TestPredictRatingsDefault(t *testing.T) {
+	got := PredictRatings("u", predictRatings, predictNeighbors)
+	checkPredictions(t, got, map[string]float64{"c": 3.5, "d": 3, "e": 4})
+}
+
+func TestCheckPredictMethod(t *testing.T) {
+	for _, m := range []string{"", PredWeighted, PredMeanCentered, PredZScore} {
+		if err := CheckPredictMethod(m); err != nil {
+			t.Errorf("CheckPredictMethod(%q): %v", m, err)
+		}
+	}
+	if err := CheckPredictMethod("median"); err == nil {
+		t.Error("CheckPredictMethod(median) sin error")
+	}
+}
+
+func TestMergeRatings(t *testing.T) {
+	csv := []Rating{{"1", "a", 3, 10}, {"1", "b", 4, 11}, {"2", "a", 5, 12}}
+	stored := []Rating{{"1", "b", 2, 20}, {"3", "c", 1, 21}, {"1", "b", 5, 22}}
+
+	got := MergeRatings(csv, stored)
+	want := []Rating{{"1", "a", 3, 10}, {"1", "b", 5, 22}, {"2", "a", 5, 12}, {"3", "c", 1, 21}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeRatings = %v, want %v", got, want)
+	}
+}
+
+func checkPredictions(t *testing.T, got []Recommended, want map[string]float64) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+		return
+	}
+	for _, r := range got {
+		w, ok := want[r.MovieID]
+		if !ok || math.IsNaN(r.Predicted) || math.Abs(r.Predicted-w) > 1e-9 {
+			t.Errorf("%s = %v, want %v (got %v)", r.MovieID, r.Predicted, w, got)
+		}
+	}
+}
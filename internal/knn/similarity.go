@@ -0,0 +1,243 @@
+package knn
+
+import (
+	"fmt"
+	"math"
+)
+
+// ---------------------------------------------------------
+// Medidas de similitud intercambiables (API, nodos ML y
+// ejecutables). Los vectores son id -> rating: películas en
+// user-based y usuarios en item-based.
+// ---------------------------------------------------------
+
+const (
+	SimCosine             = "cosine"
+	SimPearson            = "pearson"
+	SimAdjustedCosine     = "adjusted_cosine"
+	SimJaccard            = "jaccard"
+	SimConstrainedPearson = "constrained_pearson"
+)
+
+// Punto medio de la escala 0.5–5 de MovieLens para Pearson restringido
+const RatingMidpoint = 3.0
+
+type Similarity interface {
+	Name() string
+	Compute(a, b map[string]float64) float64
+}
+
+// NewSimilarity construye la medida por nombre ("" = coseno). means son
+// las medias de cada dimensión del vector (de cada película en user-based,
+// de cada usuario en item-based) y sólo las usa el coseno ajustado.
+func NewSimilarity(name string, means map[string]float64) (Similarity, error) {
+	switch name {
+	case "", SimCosine:
+		return Cosine{}, nil
+	case SimPearson:
+		return Pearson{}, nil
+	case SimAdjustedCosine:
+		return AdjustedCosine{Means: means}, nil
+	case SimJaccard:
+		return Jaccard{}, nil
+	case SimConstrainedPearson:
+		return ConstrainedPearson{Midpoint: RatingMidpoint}, nil
+	default:
+		return nil, fmt.Errorf("similitud desconocida %q", name)
+	}
+}
+
+// MeanRatings devuelve la media de cada vector (por usuario o por película)
+func MeanRatings(vectors map[string]map[string]float64) map[string]float64 {
+	means := make(map[string]float64, len(vectors))
+
+	for id, vec := range vectors {
+		if len(vec) == 0 {
+			continue
+		}
+		var sum float64
+		for _, r := range vec {
+			sum += r
+		}
+		means[id] = sum / float64(len(vec))
+	}
+	return means
+}
+
+// ---------------------------------------------------------
+// Coseno sobre los vectores completos
+// ---------------------------------------------------------
+
+type Cosine struct{}
+
+func (Cosine) Name() string { return SimCosine }
+
+func (Cosine) Compute(a, b map[string]float64) float64 {
+	return CosineSimilarity(a, b)
+}
+
+// ---------------------------------------------------------
+// Pearson: centrado en la media de cada vector sobre los
+// ítems calificados por ambos
+// ---------------------------------------------------------
+
+type Pearson struct{}
+
+func (Pearson) Name() string { return SimPearson }
+
+func (Pearson) Compute(a, b map[string]float64) float64 {
+	var sumA, sumB float64
+	n := 0
+
+	for item, ra := range a {
+		if rb, ok := b[item]; ok {
+			sumA += ra
+			sumB += rb
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+
+	meanA, meanB := sumA/float64(n), sumB/float64(n)
+
+	var num, denA, denB float64
+	for item, ra := range a {
+		if rb, ok := b[item]; ok {
+			da, db := ra-meanA, rb-meanB
+			num += da * db
+			denA += da * da
+			denB += db * db
+		}
+	}
+
+	return correlation(num, denA, denB)
+}
+
+// ---------------------------------------------------------
+// Coseno ajustado: resta la media de cada dimensión (p. ej. la
+// media de la película) antes de comparar los co-calificados
+// ---------------------------------------------------------
+
+type AdjustedCosine struct {
+	Means map[string]float64
+}
+
+func (AdjustedCosine) Name() string { return SimAdjustedCosine }
+
+func (s AdjustedCosine) Compute(a, b map[string]float64) float64 {
+	var num, denA, denB float64
+
+	for item, ra := range a {
+		if rb, ok := b[item]; ok {
+			m := s.Means[item]
+			da, db := ra-m, rb-m
+			num += da * db
+			denA += da * da
+			denB += db * db
+		}
+	}
+
+	return correlation(num, denA, denB)
+}
+
+// ---------------------------------------------------------
+// Jaccard: sólo cuenta qué se calificó, no el rating
+// ---------------------------------------------------------
+
+type Jaccard struct{}
+
+func (Jaccard) Name() string { return SimJaccard }
+
+func (Jaccard) Compute(a, b map[string]float64) float64 {
+	common := 0
+	for item := range a {
+		if _, ok := b[item]; ok {
+			common++
+		}
+	}
+
+	union := len(a) + len(b) - common
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
+// ---------------------------------------------------------
+// Pearson restringido: centrado en el punto medio de la
+// escala, así los gustos se separan en positivos/negativos
+// ---------------------------------------------------------
+
+type ConstrainedPearson struct {
+	Midpoint float64
+}
+
+func (ConstrainedPearson) Name() string { return SimConstrainedPearson }
+
+func (s ConstrainedPearson) Compute(a, b map[string]float64) float64 {
+	var num, denA, denB float64
+
+	for item, ra := range a {
+		if rb, ok := b[item]; ok {
+			da, db := ra-s.Midpoint, rb-s.Midpoint
+			num += da * db
+			denA += da * da
+			denB += db * db
+		}
+	}
+
+	return correlation(num, denA, denB)
+}
+
+func correlation(num, denA, denB float64) float64 {
+	if denA == 0 || denB == 0 {
+		return 0
+	}
+	return num / (math.Sqrt(denA) * math.Sqrt(denB))
+}
+
+// ---------------------------------------------------------
+// Filtro de vecinos: mínimo de co-calificados, ponderación
+// por significancia (Herlocker) y shrinkage hacia cero
+// ---------------------------------------------------------
+
+type NeighborFilter struct {
+	MinOverlap   int     // co-calificados mínimos para aceptar un vecino (0 = sin mínimo)
+	Significance int     // N: sim * min(n, N) / N (0 = desactivado)
+	Shrinkage    float64 // λ: sim * n / (n + λ) (0 = desactivado)
+}
+
+// Overlap cuenta los ítems calificados en ambos vectores
+func Overlap(a, b map[string]float64) int {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	n := 0
+	for item := range a {
+		if _, ok := b[item]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Apply ajusta una similitud según el número de co-calificados; devuelve
+// false si el vecino no alcanza el mínimo.
+func (f NeighborFilter) Apply(sim float64, overlap int) (float64, bool) {
+	if overlap < f.MinOverlap {
+		return 0, false
+	}
+
+	if f.Significance > 0 && overlap < f.Significance {
+		sim *= float64(overlap) / float64(f.Significance)
+	}
+
+	if f.Shrinkage > 0 {
+		sim *= float64(overlap) / (float64(overlap) + f.Shrinkage)
+	}
+
+	return sim, true
+}
@@ -0,0 +1,183 @@
+package knn
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilarities(t *testing.T) {
+	itemMeans := map[string]float64{"1": 3, "2": 3, "3": 3}
+
+	tests := []struct {
+		name string
+		sim  Similarity
+		a, b map[string]float64
+		want float64
+	}{
+		// Coseno: las normas usan todos los ítems de cada vector
+		// 20 / (sqrt(34) * sqrt(20))
+		{"cosine/vectores completos", Cosine{}, map[string]float64{"1": 5, "2": 3}, map[string]float64{"1": 4, "3": 2}, 20 / math.Sqrt(34*20)},
+		{"cosine/sin co-calificados", Cosine{}, map[string]float64{"1": 5}, map[string]float64{"2": 5}, 0},
+		{"cosine/vector vacío", Cosine{}, map[string]float64{}, map[string]float64{"2": 5}, 0},
+
+		// Pearson: sólo los co-calificados (8 y 9 no cuentan)
+		{"pearson/co-calificados", Pearson{}, map[string]float64{"1": 1, "2": 2, "3": 3, "9": 5}, map[string]float64{"1": 2, "2": 4, "3": 6, "8": 1}, 1},
+		{"pearson/opuestos", Pearson{}, map[string]float64{"1": 1, "2": 5}, map[string]float64{"1": 5, "2": 1}, -1},
+		{"pearson/sin co-calificados", Pearson{}, map[string]float64{"1": 5}, map[string]float64{"2": 5}, 0},
+		{"pearson/varianza cero", Pearson{}, map[string]float64{"1": 4, "2": 4, "3": 4}, map[string]float64{"1": 1, "2": 3, "3": 5}, 0},
+		{"pearson/varianza cero con redondeo", Pearson{}, map[string]float64{"1": 0.1, "2": 0.1, "3": 0.1}, map[string]float64{"1": 1, "2": 2, "3": 4.5}, 0},
+		// desvíos (1/6, 1/6, -1/3) y (-1/3, 1/6, 1/6): -1/12 / (1/6)
+		{"pearson/todo alto", Pearson{}, map[string]float64{"1": 5, "2": 5, "3": 4.5}, map[string]float64{"1": 4.5, "2": 5, "3": 5}, -0.5},
+
+		// Coseno ajustado: resta la media de cada ítem; (1, -1)·(2, -2) / (√2 √8)
+		{"adjusted_cosine/co-calificados", AdjustedCosine{Means: itemMeans}, map[string]float64{"1": 4, "2": 2, "9": 5}, map[string]float64{"1": 5, "2": 1, "8": 5}, 1},
+		{"adjusted_cosine/sin co-calificados", AdjustedCosine{Means: itemMeans}, map[string]float64{"1": 4}, map[string]float64{"2": 4}, 0},
+		{"adjusted_cosine/varianza cero", AdjustedCosine{Means: itemMeans}, map[string]float64{"1": 3, "2": 3}, map[string]float64{"1": 5, "2": 1}, 0},
+
+		// Jaccard: 2 comunes de 4 distintos, sin importar el rating
+		{"jaccard/co-calificados", Jaccard{}, map[string]float64{"1": 5, "2": 1, "3": 3}, map[string]float64{"2": 5, "3": 5, "4": 1}, 0.5},
+		{"jaccard/sin co-calificados", Jaccard{}, map[string]float64{"1": 5}, map[string]float64{"2": 5}, 0},
+		{"jaccard/vacíos", Jaccard{}, map[string]float64{}, map[string]float64{}, 0},
+
+		// Pearson restringido al punto medio 3: (2, 1)·(1, 2) / (√5 √5)
+		{"constrained_pearson/co-calificados", ConstrainedPearson{Midpoint: RatingMidpoint}, map[string]float64{"1": 5, "2": 4, "9": 1}, map[string]float64{"1": 4, "2": 5, "8": 1}, 0.8},
+		{"constrained_pearson/sin co-calificados", ConstrainedPearson{Midpoint: RatingMidpoint}, map[string]float64{"1": 5}, map[string]float64{"2": 5}, 0},
+		{"constrained_pearson/en el punto medio", ConstrainedPearson{Midpoint: RatingMidpoint}, map[string]float64{"1": 3, "2": 3}, map[string]float64{"1": 5, "2": 1}, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.sim.Compute(tc.a, tc.b)
+			if math.IsNaN(got) || math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Compute = %v, want %v", got, tc.want)
+			}
+			if back := tc.sim.Compute(tc.b, tc.a); math.Abs(back-got) > 1e-9 {
+				t.Errorf("no es simétrica: %v y %v", got, back)
+			}
+		})
+	}
+}
+
+// Quien califica todo alto se parece a cualquiera con el coseno, no con
+// Pearson
+func TestPearsonIgnoresRatingLevel(t *testing.T) {
+	a := map[string]float64{"1": 5, "2": 5, "3": 4.5, "4": 4.5}
+	b := map[string]float64{"1": 4.5, "2": 4.5, "3": 5, "4": 5}
+
+	if cos := (Cosine{}).Compute(a, b); cos < 0.99 {
+		t.Fatalf("coseno = %v, se esperaba casi 1", cos)
+	}
+	if p := (Pearson{}).Compute(a, b); p > 0 {
+		t.Errorf("pearson = %v, want <= 0", p)
+	}
+}
+
+func TestNewSimilarity(t *testing.T) {
+	for _, name := range []string{"", SimCosine, SimPearson, SimAdjustedCosine, SimJaccard, SimConstrainedPearson} {
+		s, err := NewSimilarity(name, nil)
+		if err != nil {
+			t.Errorf("NewSimilarity(%q): %v", name, err)
+			continue
+		}
+		if name != "" && s.Name() != name {
+			t.Errorf("NewSimilarity(%q).Name() = %q", name, s.Name())
+		}
+	}
+
+	if _, err := NewSimilarity("euclidean", nil); err == nil {
+		t.Error("NewSimilarity(euclidean) sin error")
+	}
+}
+
+func TestMeanRatings(t *testing.T) {
+	got := MeanRatings(map[string]map[string]float64{
+		"1": {"a": 4, "b": 2},
+		"2": {"a": 5},
+		"3": {},
+	})
+	if len(got) != 2 || got["1"] != 3 || got["2"] != 5 {
+		t.Errorf("MeanRatings = %v", got)
+	}
+}
+
+func TestNeighborFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  NeighborFilter
+		sim     float64
+		overlap int
+		want    float64
+		keep    bool
+	}{
+		{"sin filtro", NeighborFilter{}, 0.9, 1, 0.9, true},
+		{"bajo el mínimo", NeighborFilter{MinOverlap: 3}, 0.9, 2, 0, false},
+		{"en el mínimo", NeighborFilter{MinOverlap: 3}, 0.9, 3, 0.9, true},
+		// min(n, N) / N
+		{"significancia bajo N", NeighborFilter{Significance: 50}, 1, 10, 0.2, true},
+		{"significancia en N", NeighborFilter{Significance: 50}, 0.8, 50, 0.8, true},
+		{"significancia sobre N", NeighborFilter{Significance: 50}, 0.8, 60, 0.8, true},
+		// n / (n + λ)
+		{"shrinkage", NeighborFilter{Shrinkage: 10}, 0.8, 10, 0.4, true},
+		{"shrinkage negativo", NeighborFilter{Shrinkage: 10}, -0.6, 30, -0.45, true},
+		// 25/50 · 25/35
+		{"significancia y shrinkage", NeighborFilter{MinOverlap: 5, Significance: 50, Shrinkage: 10}, 1, 25, 0.5 * 25 / 35, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, keep := tc.filter.Apply(tc.sim, tc.overlap)
+			if keep != tc.keep || math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Apply(%v, %d) = %v, %v; want %v, %v", tc.sim, tc.overlap, got, keep, tc.want, tc.keep)
+			}
+		})
+	}
+}
+
+// Con una sola película en común las medidas sobre co-calificados dan
+// ±1.0; con el filtro ese vecino deja de dominar el top K
+func TestNeighborFilterSingleCoRated(t *testing.T) {
+	target := map[string]float64{"1": 5, "2": 3, "3": 4}
+	neighbor := map[string]float64{"1": 4, "7": 2}
+
+	sim := (ConstrainedPearson{Midpoint: RatingMidpoint}).Compute(target, neighbor)
+	overlap := Overlap(target, neighbor)
+	if sim != 1 || overlap != 1 {
+		t.Fatalf("similitud = %v, overlap = %d", sim, overlap)
+	}
+
+	tests := []struct {
+		filter NeighborFilter
+		want   float64
+		keep   bool
+	}{
+		{NeighborFilter{MinOverlap: 2}, 0, false},
+		{NeighborFilter{Significance: 50}, 1.0 / 50, true},
+		{NeighborFilter{Shrinkage: 10}, 1.0 / 11, true},
+	}
+	for _, tc := range tests {
+		got, keep := tc.filter.Apply(sim, overlap)
+		if keep != tc.keep || math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%+v: Apply = %v, %v; want %v, %v", tc.filter, got, keep, tc.want, tc.keep)
+		}
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	a := map[string]float64{"1": 5, "2": 3, "3": 4}
+	tests := []struct {
+		b    map[string]float64
+		want int
+	}{
+		{map[string]float64{"1": 1, "3": 1, "9": 2}, 2},
+		{map[string]float64{"8": 1}, 0},
+		{map[string]float64{}, 0},
+	}
+	for _, tc := range tests {
+		if got := Overlap(a, tc.b); got != tc.want {
+			t.Errorf("Overlap(a, %v) = %d, want %d", tc.b, got, tc.want)
+		}
+		if got := Overlap(tc.b, a); got != tc.want {
+			t.Errorf("Overlap(%v, a) = %d, want %d", tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package mf
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ---------------------------------------------------------
+// ALS (mínimos cuadrados alternados) con sesgos
+//
+// Cada vector tiene Factors+1 valores: los factores y al final
+// el sesgo. Con un lado fijo, el otro se resuelve por separado
+// para cada usuario (o película):
+//
+//   objetivo  r - μ - b_fijo
+//   features  [factores_fijos..., 1]
+//   solución  [factores..., sesgo]
+//
+// así el mismo cálculo sirve para usuarios y para películas, y
+// cada nodo resuelve los vectores de su shard sin coordinarse.
+// ---------------------------------------------------------
+
+// SolveALS resuelve el vector de una entidad a partir de sus ratings
+// (id del otro lado -> rating) y los vectores fijos del otro lado.
+// Devuelve false si ninguno de sus ratings tiene vector fijo.
+func SolveALS(ratings map[string]float64, fixed map[string][]float64, globalMean, reg float64) ([]float64, bool) {
+	dim := 0
+	for _, vec := range fixed {
+		dim = len(vec)
+		break
+	}
+	if dim == 0 {
+		return nil, false
+	}
+	factors := dim - 1
+
+	// Ecuaciones normales: (XᵀX + λ n I) w = Xᵀy
+	a := make([][]float64, dim)
+	for i := range a {
+		a[i] = make([]float64, dim)
+	}
+	b := make([]float64, dim)
+	x := make([]float64, dim)
+	n := 0
+
+	for id, r := range ratings {
+		vec, ok := fixed[id]
+		if !ok {
+			continue
+		}
+
+		copy(x, vec[:factors])
+		x[factors] = 1
+		y := r - globalMean - vec[factors]
+
+		for i := 0; i < dim; i++ {
+			b[i] += x[i] * y
+			for j := i; j < dim; j++ {
+				a[i][j] += x[i] * x[j]
+			}
+		}
+		n++
+	}
+	if n == 0 {
+		return nil, false
+	}
+
+	// Regularización ponderada por el número de ratings (ALS-WR)
+	for i := 0; i < dim; i++ {
+		a[i][i] += reg * float64(n)
+		for j := 0; j < i; j++ {
+			a[i][j] = a[j][i]
+		}
+	}
+
+	return solveSymmetric(a, b), true
+}
+
+// ALSError suma el error cuadrático de los ratings de una entidad dado su
+// vector y los del otro lado; devuelve la suma y cuántos ratings contó.
+func ALSError(ratings map[string]float64, vec []float64, fixed map[string][]float64, globalMean float64) (float64, int) {
+	factors := len(vec) - 1
+
+	var sq float64
+	n := 0
+	for id, r := range ratings {
+		other, ok := fixed[id]
+		if !ok {
+			continue
+		}
+
+		pred := globalMean + vec[factors] + other[factors]
+		for f := 0; f < factors; f++ {
+			pred += vec[f] * other[f]
+		}
+		err := r - pred
+		sq += err * err
+		n++
+	}
+	return sq, n
+}
+
+// solveSymmetric resuelve a·w = b para a simétrica definida positiva
+// (Cholesky); a se sobrescribe.
+func solveSymmetric(a [][]float64, b []float64) []float64 {
+	n := len(b)
+
+	for j := 0; j < n; j++ {
+		sum := a[j][j]
+		for k := 0; k < j; k++ {
+			sum -= a[j][k] * a[j][k]
+		}
+		if sum <= 0 {
+			sum = 1e-12
+		}
+		a[j][j] = math.Sqrt(sum)
+
+		for i := j + 1; i < n; i++ {
+			s := a[i][j]
+			for k := 0; k < j; k++ {
+				s -= a[i][k] * a[j][k]
+			}
+			a[i][j] = s / a[j][j]
+		}
+	}
+
+	// L z = b
+	z := make([]float64, n)
+	for i := 0; i < n; i++ {
+		s := b[i]
+		for k := 0; k < i; k++ {
+			s -= a[i][k] * z[k]
+		}
+		z[i] = s / a[i][i]
+	}
+
+	// Lᵀ w = z
+	w := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		s := z[i]
+		for k := i + 1; k < n; k++ {
+			s -= a[k][i] * w[k]
+		}
+		w[i] = s / a[i][i]
+	}
+	return w
+}
+
+// InitALSVectors crea vectores aleatorios pequeños (sesgo en cero) para
+// los ids dados, en orden estable para que el seed sea reproducible.
+func InitALSVectors(ids []string, factors int, stdDev float64, seed int64) map[string][]float64 {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make(map[string][]float64, len(sorted))
+	for _, id := range sorted {
+		vec := make([]float64, factors+1)
+		for f := 0; f < factors; f++ {
+			vec[f] = rng.NormFloat64() * stdDev
+		}
+		vectors[id] = vec
+	}
+	return vectors
+}
+
+// FromALS arma un Model a partir de los vectores [factores..., sesgo] de
+// usuarios y películas.
+func FromALS(users, items map[string][]float64, factors int, globalMean float64) *Model {
+	m := &Model{
+		Factors:    factors,
+		GlobalMean: globalMean,
+		Users:      make(map[string]int, len(users)),
+		Items:      make(map[string]int, len(items)),
+	}
+
+	m.UserBias, m.P = splitVectors(users, m.Users, factors)
+	m.ItemBias, m.Q = splitVectors(items, m.Items, factors)
+	return m
+}
+
+func splitVectors(vectors map[string][]float64, index map[string]int, factors int) ([]float64, [][]float64) {
+	ids := make([]string, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	bias := make([]float64, len(ids))
+	mat := make([][]float64, len(ids))
+	for row, id := range ids {
+		vec := vectors[id]
+		index[id] = row
+		mat[row] = append([]float64(nil), vec[:factors]...)
+		bias[row] = vec[factors]
+	}
+	return bias, mat
+}
+
+// FoldIn devuelve una copia del modelo en la que los usuarios de users
+// (id -> todos sus ratings) se vuelven a resolver por ALS con los vectores
+// de películas fijos, sin reentrenar. Los usuarios nuevos se agregan al
+// final; las películas que el modelo no conoce se ignoran. El modelo
+// original no se modifica, así que puede seguir sirviendo mientras tanto.
+func (m *Model) FoldIn(users map[string]map[string]float64, reg float64) *Model {
+	next := *m
+	next.Users = make(map[string]int, len(m.Users)+len(users))
+	for id, row := range m.Users {
+		next.Users[id] = row
+	}
+	next.UserBias = append([]float64(nil), m.UserBias...)
+	next.P = append([][]float64(nil), m.P...)
+
+	for user, ratings := range users {
+		fixed := make(map[string][]float64, len(ratings))
+		for movie := range ratings {
+			if i, ok := m.Items[movie]; ok {
+				fixed[movie] = append(append(make([]float64, 0, m.Factors+1), m.Q[i]...), m.ItemBias[i])
+			}
+		}
+
+		vec, ok := SolveALS(ratings, fixed, m.GlobalMean, reg)
+		if !ok {
+			continue
+		}
+
+		row, known := next.Users[user]
+		if !known {
+			row = len(next.P)
+			next.Users[user] = row
+			next.P = append(next.P, nil)
+			next.UserBias = append(next.UserBias, 0)
+		}
+		next.P[row] = vec[:m.Factors]
+		next.UserBias[row] = vec[m.Factors]
+	}
+	return &next
+}
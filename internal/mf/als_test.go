@@ -0,0 +1,167 @@
+package mf
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func near(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSolveSymmetric(t *testing.T) {
+	tests := []struct {
+		name string
+		a    [][]float64
+		b    []float64
+		want []float64
+	}{
+		{
+			// A·(1, -1, 2) = (2, 3, 9)
+			name: "3x3",
+			a:    [][]float64{{4, 2, 0}, {2, 5, 3}, {0, 3, 6}},
+			b:    []float64{2, 3, 9},
+			want: []float64{1, -1, 2},
+		},
+		{
+			name: "diagonal",
+			a:    [][]float64{{2, 0}, {0, 8}},
+			b:    []float64{1, 2},
+			want: []float64{0.5, 0.25},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := solveSymmetric(tc.a, tc.b); !near(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// Un factor: películas x = (1, sesgo 0.5) e y = (2, sesgo 0.5), μ = 3.
+// Objetivos r - μ - b_i = 4 y 6 con features (1, 1) y (2, 1)
+func TestSolveALS(t *testing.T) {
+	fixed := map[string][]float64{"x": {1, 0.5}, "y": {2, 0.5}}
+	ratings := map[string]float64{"x": 7.5, "y": 9.5, "z": 1}
+
+	tests := []struct {
+		name string
+		reg  float64
+		want []float64
+	}{
+		// solución exacta: factor 2, sesgo 2
+		{"sin regularización", 0, []float64{2, 2}},
+		// (XᵀX + 0.5·2·I) w = Xᵀy: [[6 3] [3 3]] w = (16, 10)
+		{"con regularización", 0.5, []float64{2, 4.0 / 3}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := SolveALS(ratings, fixed, 3, tc.reg)
+			if !ok || !near(got, tc.want) {
+				t.Errorf("SolveALS = %v, %v; want %v", got, ok, tc.want)
+			}
+		})
+	}
+
+	if _, ok := SolveALS(map[string]float64{"z": 4}, fixed, 3, 0.1); ok {
+		t.Error("SolveALS sin vectores fijos devolvió ok")
+	}
+	if _, ok := SolveALS(ratings, map[string][]float64{}, 3, 0.1); ok {
+		t.Error("SolveALS con fixed vacío devolvió ok")
+	}
+}
+
+func TestALSError(t *testing.T) {
+	fixed := map[string][]float64{"x": {1, 0}, "y": {2, 0}}
+
+	// predicciones 2 + 2·1 = 4 y 2 + 2·2 = 6; z no tiene vector
+	sq, n := ALSError(map[string]float64{"x": 4.5, "y": 6, "z": 1}, []float64{2, 2}, fixed, 0)
+	if n != 2 || math.Abs(sq-0.25) > 1e-12 {
+		t.Errorf("ALSError = %v, %d; want 0.25, 2", sq, n)
+	}
+}
+
+func TestFromALS(t *testing.T) {
+	users := map[string][]float64{"u": {1, 0.5}, "v": {-1, 0}}
+	items := map[string][]float64{"x": {2, -0.25}}
+
+	m := FromALS(users, items, 1, 3)
+	// μ + b_u + b_i + p·q
+	if got := m.Predict("u", "x"); math.Abs(got-(3+0.5-0.25+2)) > 1e-12 {
+		t.Errorf("Predict(u, x) = %v", got)
+	}
+	if got := m.Predict("v", "x"); math.Abs(got-(3-0.25-2)) > 1e-12 {
+		t.Errorf("Predict(v, x) = %v", got)
+	}
+}
+
+func TestInitALSVectors(t *testing.T) {
+	a := InitALSVectors([]string{"b", "a", "c"}, 3, 0.1, 7)
+	b := InitALSVectors([]string{"c", "b", "a"}, 3, 0.1, 7)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("el orden de los ids cambia los vectores")
+	}
+	for id, vec := range a {
+		if len(vec) != 4 || vec[3] != 0 {
+			t.Errorf("vector de %s = %v, want 3 factores y sesgo 0", id, vec)
+		}
+	}
+}
+
+func TestFoldIn(t *testing.T) {
+	m := FromALS(
+		map[string][]float64{"u": {1, 0}},
+		map[string][]float64{"x": {1, 0}, "y": {2, 0}},
+		1, 0,
+	)
+	users, bias, p := copyUsers(m)
+
+	// v es nuevo y u se vuelve a resolver; z no está en el modelo
+	next := m.FoldIn(map[string]map[string]float64{
+		"u": {"x": 4, "y": 6},
+		"v": {"x": 4, "y": 6, "z": 5},
+	}, 0)
+
+	// factor 2, sesgo 2 (ver TestSolveALS)
+	for _, user := range []string{"u", "v"} {
+		if got := next.Predict(user, "x"); math.Abs(got-4) > 1e-9 {
+			t.Errorf("Predict(%s, x) = %v, want 4", user, got)
+		}
+	}
+	if len(next.Users) != 2 || next.Users["u"] != 0 {
+		t.Errorf("Users = %v", next.Users)
+	}
+
+	if !reflect.DeepEqual(m.Users, users) || !reflect.DeepEqual(m.UserBias, bias) || !reflect.DeepEqual(m.P, p) {
+		t.Errorf("FoldIn modificó el modelo original: %v %v %v", m.Users, m.UserBias, m.P)
+	}
+	if got := m.Predict("u", "x"); got != 1 {
+		t.Errorf("modelo original Predict(u, x) = %v, want 1", got)
+	}
+	if _, ok := m.Users["v"]; ok {
+		t.Error("el modelo original tiene al usuario nuevo")
+	}
+}
+
+func copyUsers(m *Model) (map[string]int, []float64, [][]float64) {
+	users := make(map[string]int, len(m.Users))
+	for id, row := range m.Users {
+		users[id] = row
+	}
+	p := make([][]float64, len(m.P))
+	for i, row := range m.P {
+		p[i] = append([]float64(nil), row...)
+	}
+	return users, append([]float64(nil), m.UserBias...), p
+}
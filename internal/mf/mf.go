@@ -0,0 +1,285 @@
+package mf
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"pcd-pc4/internal/knn"
+)
+
+// ---------------------------------------------------------
+// Modelo de factores latentes con sesgos:
+//   r̂(u,i) = μ + b_u + b_i + p_u · q_i
+// ---------------------------------------------------------
+
+type Model struct {
+	Factors    int
+	GlobalMean float64
+
+	Users map[string]int // id de usuario -> fila de UserBias / P
+	Items map[string]int // id de película -> fila de ItemBias / Q
+
+	UserBias []float64
+	ItemBias []float64
+	P        [][]float64 // factores de usuarios
+	Q        [][]float64 // factores de películas
+}
+
+// Config: hiperparámetros del entrenamiento por SGD
+type Config struct {
+	Factors      int
+	Epochs       int
+	LearningRate float64
+	Reg          float64 // regularización L2 de sesgos y factores
+	InitStdDev   float64 // dispersión inicial de los factores
+	Seed         int64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Factors:      50,
+		Epochs:       20,
+		LearningRate: 0.01,
+		Reg:          0.05,
+		InitStdDev:   0.1,
+		Seed:         42,
+	}
+}
+
+// rating en forma de índices para recorrer el dataset rápido
+type sample struct {
+	user, item int
+	value      float64
+}
+
+// ---------------------------------------------------------
+// Inicialización
+// ---------------------------------------------------------
+
+// NewModel crea un modelo con sesgos en cero y factores aleatorios
+// pequeños para todos los usuarios y películas de ratings.
+func NewModel(ratings map[string]map[string]float64, factors int, stdDev float64, rng *rand.Rand) *Model {
+	m := &Model{
+		Factors: factors,
+		Users:   make(map[string]int),
+		Items:   make(map[string]int),
+	}
+
+	users := make([]string, 0, len(ratings))
+	for u := range ratings {
+		users = append(users, u)
+	}
+	sort.Strings(users) // orden estable: mismo seed, mismo modelo
+
+	var sum float64
+	n := 0
+	items := []string{}
+
+	for _, u := range users {
+		m.Users[u] = len(m.Users)
+		for i, r := range ratings[u] {
+			if _, ok := m.Items[i]; !ok {
+				m.Items[i] = -1
+				items = append(items, i)
+			}
+			sum += r
+			n++
+		}
+	}
+	sort.Strings(items)
+	for idx, i := range items {
+		m.Items[i] = idx
+	}
+
+	if n > 0 {
+		m.GlobalMean = sum / float64(n)
+	}
+
+	m.UserBias = make([]float64, len(users))
+	m.ItemBias = make([]float64, len(items))
+	m.P = randomMatrix(len(users), factors, stdDev, rng)
+	m.Q = randomMatrix(len(items), factors, stdDev, rng)
+
+	return m
+}
+
+func randomMatrix(rows, cols int, stdDev float64, rng *rand.Rand) [][]float64 {
+	mat := make([][]float64, rows)
+	for r := range mat {
+		mat[r] = make([]float64, cols)
+		for c := range mat[r] {
+			mat[r][c] = rng.NormFloat64() * stdDev
+		}
+	}
+	return mat
+}
+
+// ---------------------------------------------------------
+// Entrenamiento por SGD
+// ---------------------------------------------------------
+
+// Train ajusta el modelo recorriendo los ratings en orden aleatorio en
+// cada época; progress (opcional) recibe el RMSE de entrenamiento.
+func Train(ratings map[string]map[string]float64, cfg Config, progress func(epoch int, rmse float64)) *Model {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	m := NewModel(ratings, cfg.Factors, cfg.InitStdDev, rng)
+
+	samples := make([]sample, 0)
+	for u, rs := range ratings {
+		for i, r := range rs {
+			samples = append(samples, sample{user: m.Users[u], item: m.Items[i], value: r})
+		}
+	}
+	// El orden de los maps no es estable: ordenar antes de barajar
+	sort.Slice(samples, func(a, b int) bool {
+		if samples[a].user != samples[b].user {
+			return samples[a].user < samples[b].user
+		}
+		return samples[a].item < samples[b].item
+	})
+
+	lr, reg := cfg.LearningRate, cfg.Reg
+
+	for epoch := 1; epoch <= cfg.Epochs; epoch++ {
+		rng.Shuffle(len(samples), func(a, b int) {
+			samples[a], samples[b] = samples[b], samples[a]
+		})
+
+		var sq float64
+		for _, s := range samples {
+			pu, qi := m.P[s.user], m.Q[s.item]
+
+			err := s.value - m.predictIndex(s.user, s.item)
+			sq += err * err
+
+			m.UserBias[s.user] += lr * (err - reg*m.UserBias[s.user])
+			m.ItemBias[s.item] += lr * (err - reg*m.ItemBias[s.item])
+
+			for f := 0; f < m.Factors; f++ {
+				puf, qif := pu[f], qi[f]
+				pu[f] += lr * (err*qif - reg*puf)
+				qi[f] += lr * (err*puf - reg*qif)
+			}
+		}
+
+		if progress != nil && len(samples) > 0 {
+			progress(epoch, math.Sqrt(sq/float64(len(samples))))
+		}
+	}
+
+	return m
+}
+
+// ---------------------------------------------------------
+// Predicción y recomendación
+// ---------------------------------------------------------
+
+func (m *Model) predictIndex(u, i int) float64 {
+	pred := m.GlobalMean + m.UserBias[u] + m.ItemBias[i]
+	pu, qi := m.P[u], m.Q[i]
+	for f := range pu {
+		pred += pu[f] * qi[f]
+	}
+	return pred
+}
+
+// Predict estima el rating; para usuarios o películas que el modelo no
+// conoce se usan sólo los sesgos disponibles.
+func (m *Model) Predict(user, item string) float64 {
+	u, uok := m.Users[user]
+	i, iok := m.Items[item]
+
+	switch {
+	case uok && iok:
+		return m.predictIndex(u, i)
+	case uok:
+		return m.GlobalMean + m.UserBias[u]
+	case iok:
+		return m.GlobalMean + m.ItemBias[i]
+	default:
+		return m.GlobalMean
+	}
+}
+
+// Recommend puntúa todas las películas que el usuario no calificó y
+// devuelve las n mejores.
+func (m *Model) Recommend(user string, seen map[string]float64, n int) ([]knn.Recommended, error) {
+	u, ok := m.Users[user]
+	if !ok {
+		return nil, fmt.Errorf("usuario %s no está en el modelo", user)
+	}
+
+	recs := make([]knn.Recommended, 0, len(m.Items))
+	for item, i := range m.Items {
+		if _, done := seen[item]; done {
+			continue
+		}
+		recs = append(recs, knn.Recommended{
+			MovieID:   item,
+			Predicted: m.predictIndex(u, i),
+		})
+	}
+
+	return knn.TopNRecommendations(recs, n), nil
+}
+
+// RMSE sobre un conjunto de ratings (usuario -> película -> rating)
+func (m *Model) RMSE(ratings map[string]map[string]float64) float64 {
+	var sq float64
+	n := 0
+
+	for u, rs := range ratings {
+		for i, r := range rs {
+			err := r - m.Predict(u, i)
+			sq += err * err
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 0
+	}
+	return math.Sqrt(sq / float64(n))
+}
+
+// ---------------------------------------------------------
+// Persistencia (gob)
+// ---------------------------------------------------------
+
+func (m *Model) Save(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func Load(path string) (*Model, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m Model
+	if err := gob.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("modelo %s inválido: %w", path, err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,107 @@
+package mf
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// μ 3, b_u 0.5, p_u (2); películas a: b -1, q (0.5); b: b 0.25, q (-1)
+func handModel() *Model {
+	return &Model{
+		Factors:    1,
+		GlobalMean: 3,
+		Users:      map[string]int{"u": 0},
+		Items:      map[string]int{"a": 0, "b": 1},
+		UserBias:   []float64{0.5},
+		ItemBias:   []float64{-1, 0.25},
+		P:          [][]float64{{2}},
+		Q:          [][]float64{{0.5}, {-1}},
+	}
+}
+
+func TestPredict(t *testing.T) {
+	m := handModel()
+
+	tests := []struct {
+		user, item string
+		want       float64
+	}{
+		{"u", "a", 3 + 0.5 - 1 + 2*0.5},
+		{"u", "b", 3 + 0.5 + 0.25 - 2},
+		{"u", "z", 3 + 0.5}, // película desconocida: sólo el sesgo del usuario
+		{"x", "a", 3 - 1},   // usuario desconocido: sólo el sesgo de la película
+		{"x", "z", 3},
+	}
+	for _, tc := range tests {
+		if got := m.Predict(tc.user, tc.item); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("Predict(%s, %s) = %v, want %v", tc.user, tc.item, got, tc.want)
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	m := handModel()
+
+	recs, err := m.Recommend("u", map[string]float64{"a": 4}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].MovieID != "b" || math.Abs(recs[0].Predicted-1.75) > 1e-12 {
+		t.Errorf("Recommend = %v", recs)
+	}
+
+	if _, err := m.Recommend("x", nil, 10); err == nil {
+		t.Error("Recommend de un usuario desconocido sin error")
+	}
+}
+
+func TestTrain(t *testing.T) {
+	ratings := map[string]map[string]float64{
+		"1": {"a": 5, "b": 4, "c": 1},
+		"2": {"a": 4, "b": 5, "d": 2},
+		"3": {"c": 5, "d": 4, "a": 1},
+		"4": {"c": 4, "d": 5, "b": 2},
+	}
+	cfg := Config{Factors: 2, Epochs: 300, LearningRate: 0.05, Reg: 0.01, InitStdDev: 0.1, Seed: 1}
+
+	var first, last float64
+	m := Train(ratings, cfg, func(epoch int, rmse float64) {
+		if epoch == 1 {
+			first = rmse
+		}
+		last = rmse
+	})
+
+	if m.GlobalMean != 42.0/12 {
+		t.Errorf("GlobalMean = %v, want %v", m.GlobalMean, 42.0/12)
+	}
+	if last >= first || last > 0.2 {
+		t.Errorf("RMSE de entrenamiento %v -> %v", first, last)
+	}
+	if rmse := m.RMSE(ratings); math.Abs(rmse-last) > 0.1 {
+		t.Errorf("RMSE = %v, última época %v", rmse, last)
+	}
+
+	// mismo seed, mismo modelo
+	if again := Train(ratings, cfg, nil); !reflect.DeepEqual(again, m) {
+		t.Error("Train no es reproducible con el mismo seed")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models", "mf.gob")
+
+	m := handModel()
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("Load = %+v, want %+v", got, m)
+	}
+}
@@ -0,0 +1,94 @@
+package popularity
+
+import (
+	"sort"
+
+	"pcd-pc4/internal/knn"
+)
+
+// ---------------------------------------------------------
+// Popularidad con promedio bayesiano: una película con pocos
+// votos se acerca a la media global C; con muchos, a su media
+//   score = v/(v+m) · R + m/(v+m) · C
+// ---------------------------------------------------------
+
+type Model struct {
+	MinVotes   float64            // m: votos con los que la media propia pesa la mitad
+	GlobalMean float64            // C
+	Scores     map[string]float64 // película -> promedio bayesiano
+	Votes      map[string]int     // película -> número de ratings
+}
+
+// Build calcula el promedio bayesiano de cada película a partir de
+// película -> usuario -> rating.
+func Build(itemRatings map[string]map[string]float64, minVotes float64) *Model {
+	m := &Model{
+		MinVotes: minVotes,
+		Scores:   make(map[string]float64, len(itemRatings)),
+		Votes:    make(map[string]int, len(itemRatings)),
+	}
+
+	var total float64
+	n := 0
+	for _, ratings := range itemRatings {
+		for _, r := range ratings {
+			total += r
+			n++
+		}
+	}
+	if n > 0 {
+		m.GlobalMean = total / float64(n)
+	}
+
+	for movie, ratings := range itemRatings {
+		var sum float64
+		for _, r := range ratings {
+			sum += r
+		}
+		v := float64(len(ratings))
+		if v == 0 {
+			continue
+		}
+
+		mean := sum / v
+		m.Scores[movie] = v/(v+minVotes)*mean + minVotes/(v+minVotes)*m.GlobalMean
+		m.Votes[movie] = len(ratings)
+	}
+
+	return m
+}
+
+// Score devuelve el promedio bayesiano (la media global si no hay votos)
+func (m *Model) Score(movie string) float64 {
+	if s, ok := m.Scores[movie]; ok {
+		return s
+	}
+	return m.GlobalMean
+}
+
+// Top devuelve las n películas mejor puntuadas que no estén en seen y
+// cumplan keep (nil = todas).
+func (m *Model) Top(n int, seen map[string]float64, keep func(movie string) bool) []knn.Recommended {
+	recs := make([]knn.Recommended, 0, len(m.Scores))
+	for movie, s := range m.Scores {
+		if _, done := seen[movie]; done {
+			continue
+		}
+		if keep != nil && !keep(movie) {
+			continue
+		}
+		recs = append(recs, knn.Recommended{MovieID: movie, Predicted: s})
+	}
+
+	// Desempate estable por id para respuestas reproducibles
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Predicted != recs[j].Predicted {
+			return recs[i].Predicted > recs[j].Predicted
+		}
+		return recs[i].MovieID < recs[j].MovieID
+	})
+	if len(recs) > n {
+		return recs[:n]
+	}
+	return recs
+}
@@ -0,0 +1,97 @@
+package popularity
+
+import (
+	"math"
+	"testing"
+)
+
+// a: 2 votos de media 5; b: 4 votos de media 3; c: 1 voto de 1.
+// Media global C = 23/7
+var itemRatings = map[string]map[string]float64{
+	"a": {"1": 5, "2": 5},
+	"b": {"1": 3, "2": 4, "3": 2, "4": 3},
+	"c": {"5": 1},
+}
+
+func TestBuild(t *testing.T) {
+	const c = 23.0 / 7
+
+	tests := []struct {
+		name     string
+		minVotes float64
+		want     map[string]float64
+	}{
+		// v/(v+m)·R + m/(v+m)·C con m = 2
+		{"m=2", 2, map[string]float64{
+			"a": 2.0/4*5 + 2.0/4*c,
+			"b": 4.0/6*3 + 2.0/6*c,
+			"c": 1.0/3*1 + 2.0/3*c,
+		}},
+		// sin votos mínimos es la media de cada película
+		{"m=0", 0, map[string]float64{"a": 5, "b": 3, "c": 1}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := Build(itemRatings, tc.minVotes)
+			if math.Abs(m.GlobalMean-c) > 1e-12 {
+				t.Errorf("GlobalMean = %v, want %v", m.GlobalMean, c)
+			}
+			for movie, want := range tc.want {
+				if got := m.Score(movie); math.Abs(got-want) > 1e-12 {
+					t.Errorf("Score(%s) = %v, want %v", movie, got, want)
+				}
+			}
+			if m.Votes["b"] != 4 {
+				t.Errorf("Votes[b] = %d", m.Votes["b"])
+			}
+			// sin votos: la media global
+			if got := m.Score("z"); math.Abs(got-c) > 1e-12 {
+				t.Errorf("Score(z) = %v, want %v", got, c)
+			}
+		})
+	}
+}
+
+func TestTop(t *testing.T) {
+	m := Build(itemRatings, 2)
+
+	tests := []struct {
+		name string
+		n    int
+		seen map[string]float64
+		keep func(string) bool
+		want []string
+	}{
+		{"todas", 10, nil, nil, []string{"a", "b", "c"}},
+		{"corte", 2, nil, nil, []string{"a", "b"}},
+		{"sin vistas", 10, map[string]float64{"a": 4}, nil, []string{"b", "c"}},
+		{"filtro", 10, nil, func(movie string) bool { return movie != "b" }, []string{"a", "c"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := m.Top(tc.n, tc.seen, tc.keep)
+			if len(got) != len(tc.want) {
+				t.Fatalf("Top = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i].MovieID != tc.want[i] {
+					t.Errorf("Top = %v, want %v", got, tc.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Empates por id: la respuesta no depende del orden del map
+func TestTopTies(t *testing.T) {
+	m := Build(map[string]map[string]float64{
+		"20": {"1": 4}, "3": {"1": 4}, "100": {"1": 4},
+	}, 0)
+
+	got := m.Top(3, nil, nil)
+	if len(got) != 3 || got[0].MovieID != "100" || got[1].MovieID != "20" || got[2].MovieID != "3" {
+		t.Errorf("Top = %v", got)
+	}
+}
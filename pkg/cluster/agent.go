@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"pcd-pc4/pkg/network"
+)
+
+// -----------------------------------------------------------
+// Agente de membresía (lado nodo ML)
+// -----------------------------------------------------------
+
+// SignatureHeader lleva la firma HMAC de join / heartbeat / leave cuando
+// el cluster usa secreto compartido, para que nadie más pueda registrarse
+// como nodo y recibir shards.
+const SignatureHeader = "X-Node-Signature"
+
+// SignedPayload es lo que cubre la firma de una operación de membresía
+func SignedPayload(action, addr string) string {
+	return action + "|" + addr
+}
+
+var httpClient = &http.Client{Timeout: 3 * time.Second}
+
+// Agent registra un nodo en el API y mantiene sus heartbeats
+type Agent struct {
+	APIURL string                 // p. ej. http://pcd-pc4_api:8080
+	Addr   string                 // dirección TCP anunciada por el nodo
+	Auth   *network.Authenticator // nil = peticiones sin firmar
+}
+
+// Join se registra en el API, reintentando hasta que responda
+func (a *Agent) Join(retry time.Duration) {
+	for {
+		err := a.post("join")
+		if err == nil {
+			fmt.Println("Nodo registrado en", a.APIURL, "como", a.Addr)
+			return
+		}
+
+		fmt.Println("Registro fallido, reintentando:", err)
+		time.Sleep(retry)
+	}
+}
+
+// Heartbeats envía latidos periódicos hasta que se cierre stop
+func (a *Agent) Heartbeats(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := a.post("heartbeat"); err != nil {
+				fmt.Println("Heartbeat fallido:", err)
+			}
+		}
+	}
+}
+
+// Leave avisa al API que el nodo se retira ordenadamente
+func (a *Agent) Leave() error {
+	return a.post("leave")
+}
+
+func (a *Agent) post(action string) error {
+	body, err := json.Marshal(NodeInfo{Addr: a.Addr})
+	if err != nil {
+		return err
+	}
+
+	url := a.APIURL + "/nodes/" + action
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.Auth != nil {
+		req.Header.Set(SignatureHeader, a.Auth.Sign(SignedPayload(action, a.Addr)))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s respondió %s", url, resp.Status)
+	}
+	return nil
+}
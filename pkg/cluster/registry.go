@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------
+// Registro dinámico de nodos ML (lado API / coordinador)
+// -----------------------------------------------------------
+
+// NodeInfo es el cuerpo JSON de join / heartbeat / leave
+type NodeInfo struct {
+	Addr string `json:"addr"` // dirección TCP anunciada por el nodo
+}
+
+// Registry guarda los nodos vivos y el último heartbeat de cada uno.
+// onChange se invoca (fuera del lock) cada vez que cambia la membresía.
+type Registry struct {
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+	timeout  time.Duration
+	onChange func(alive []string)
+}
+
+func NewRegistry(timeout time.Duration, onChange func(alive []string)) *Registry {
+	return &Registry{
+		lastSeen: make(map[string]time.Time),
+		timeout:  timeout,
+		onChange: onChange,
+	}
+}
+
+// Join registra un nodo; un heartbeat de un nodo desconocido (p. ej. tras
+// reiniciar el API) también cuenta como join.
+func (r *Registry) Join(addr string) {
+	r.mu.Lock()
+	_, known := r.lastSeen[addr]
+	r.lastSeen[addr] = time.Now()
+	alive := r.aliveLocked()
+	r.mu.Unlock()
+
+	if !known {
+		r.notify(alive)
+	}
+}
+
+func (r *Registry) Heartbeat(addr string) {
+	r.Join(addr)
+}
+
+func (r *Registry) Leave(addr string) {
+	r.mu.Lock()
+	_, known := r.lastSeen[addr]
+	delete(r.lastSeen, addr)
+	alive := r.aliveLocked()
+	r.mu.Unlock()
+
+	if known {
+		r.notify(alive)
+	}
+}
+
+// Alive devuelve los nodos vivos ordenados (orden estable entre llamadas)
+func (r *Registry) Alive() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.aliveLocked()
+}
+
+// Sweep elimina periódicamente los nodos que dejaron de enviar heartbeats
+func (r *Registry) Sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.mu.Lock()
+		removed := false
+		for addr, seen := range r.lastSeen {
+			if time.Since(seen) > r.timeout {
+				delete(r.lastSeen, addr)
+				removed = true
+			}
+		}
+		alive := r.aliveLocked()
+		r.mu.Unlock()
+
+		if removed {
+			r.notify(alive)
+		}
+	}
+}
+
+func (r *Registry) aliveLocked() []string {
+	alive := make([]string, 0, len(r.lastSeen))
+	for addr := range r.lastSeen {
+		alive = append(alive, addr)
+	}
+	sort.Strings(alive)
+	return alive
+}
+
+func (r *Registry) notify(alive []string) {
+	if r.onChange != nil {
+		r.onChange(alive)
+	}
+}
@@ -1,30 +1,81 @@
-package database
-
-// -----------------------------------------------------------
-// DOCUMENTO: Recomendación generada para un usuario
-// Colección: recommendations
-// -----------------------------------------------------------
-
-type RecommendedItem struct {
-	MovieID   string  `bson:"movie_id" json:"movie_id"`
-	Predicted float64 `bson:"predicted" json:"predicted"`
-}
-
-type RecommendationDocument struct {
-	UserID        string            `bson:"user_id" json:"user_id"`
-	Recommended   []RecommendedItem `bson:"recommended" json:"recommended"`
-	LatencyMS     int64             `bson:"latency_ms" json:"latency_ms"`
-	TimestampUnix int64             `bson:"timestamp" json:"timestamp"`
-}
-
-// -----------------------------------------------------------
-// DOCUMENTO: Log del proceso distribuido
-// Colección: logs
-// -----------------------------------------------------------
-
-type LogDocument struct {
-	UserID        string `bson:"user_id" json:"user_id"`
-	NodeCount     int    `bson:"node_count" json:"node_count"`
-	LatencyMS     int64  `bson:"latency_ms" json:"latency_ms"`
-	TimestampUnix int64  `bson:"timestamp" json:"timestamp"`
-}
+package database
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// -----------------------------------------------------------
+// DOCUMENTO: Recomendación generada para un usuario. Model es el
+// modelo pedido; ColdStart indica que la lista vino (toda o en
+// parte) de populares por falta de historial
+// Colección: recommendations
+// -----------------------------------------------------------
+
+type RecommendedItem struct {
+	MovieID   string  `bson:"movie_id" json:"movie_id"`
+	Predicted float64 `bson:"predicted" json:"predicted"`
+}
+
+type RecommendationDocument struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	Model         string             `bson:"model" json:"model"`
+	ColdStart     string             `bson:"cold_start,omitempty" json:"cold_start,omitempty"`
+	Experiment    string             `bson:"experiment,omitempty" json:"experiment,omitempty"`
+	Arm           string             `bson:"arm,omitempty" json:"arm,omitempty"`
+	Recommended   []RecommendedItem  `bson:"recommended" json:"recommended"`
+	LatencyMS     int64              `bson:"latency_ms" json:"latency_ms"`
+	TimestampUnix int64              `bson:"timestamp" json:"timestamp"`
+}
+
+// -----------------------------------------------------------
+// DOCUMENTO: Reacción de un usuario a una película de una
+// recomendación servida (impresión, clic, vista o rating); copia
+// el modelo, el cold start, el brazo y el instante de la recomendación
+// Colección: feedback
+// -----------------------------------------------------------
+
+const (
+	EventImpression = "impression" // la película se mostró
+	EventClick      = "click"      // el usuario la abrió
+	EventWatch      = "watch"      // la vio (conversión)
+	EventRating     = "rating"     // la calificó (conversión)
+)
+
+type FeedbackDocument struct {
+	RecommendationID primitive.ObjectID `bson:"recommendation_id" json:"recommendation_id"`
+
+	UserID        string  `bson:"user_id" json:"user_id"`
+	MovieID       string  `bson:"movie_id" json:"movie_id"`
+	Event         string  `bson:"event" json:"event"`
+	Rating        float64 `bson:"rating,omitempty" json:"rating,omitempty"`
+	Model         string  `bson:"model" json:"model"`
+	ColdStart     string  `bson:"cold_start,omitempty" json:"cold_start,omitempty"`
+	Experiment    string  `bson:"experiment,omitempty" json:"experiment,omitempty"`
+	Arm           string  `bson:"arm,omitempty" json:"arm,omitempty"`
+	RecommendedAt int64   `bson:"recommended_at" json:"recommended_at"`
+	TimestampUnix int64   `bson:"timestamp" json:"timestamp"`
+}
+
+// -----------------------------------------------------------
+// DOCUMENTO: Rating recibido por POST /ratings (se suma a los
+// del CSV al arrancar el API)
+// Colección: ratings
+// -----------------------------------------------------------
+
+type RatingDocument struct {
+	UserID        string  `bson:"user_id" json:"user_id"`
+	MovieID       string  `bson:"movie_id" json:"movie_id"`
+	Rating        float64 `bson:"rating" json:"rating"`
+	TimestampUnix int64   `bson:"timestamp" json:"timestamp"`
+}
+
+// -----------------------------------------------------------
+// DOCUMENTO: Log del proceso distribuido
+// Colección: logs
+// -----------------------------------------------------------
+
+type LogDocument struct {
+	UserID        string `bson:"user_id" json:"user_id"`
+	NodeCount     int    `bson:"node_count" json:"node_count"`
+	LatencyMS     int64  `bson:"latency_ms" json:"latency_ms"`
+	TimestampUnix int64  `bson:"timestamp" json:"timestamp"`
+}
@@ -1,41 +1,62 @@
-package database
-
-import (
-	"context"
-	"time"
-
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-// -------------------------
-// Cliente Mongo Global
-// -------------------------
-
-var Client *mongo.Client
-
-func Connect(uri string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
-	if err != nil {
-		return err
-	}
-
-	// Verificar conexión
-	if err := client.Ping(ctx, nil); err != nil {
-		return err
-	}
-
-	Client = client
-	return nil
-}
-
-func RecsCollection() *mongo.Collection {
-	return Client.Database("pcd").Collection("recommendations")
-}
-
-func LogsCollection() *mongo.Collection {
-	return Client.Database("pcd").Collection("logs")
-}
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// -------------------------
+// Cliente Mongo Global
+// -------------------------
+
+var Client *mongo.Client
+
+func Connect(uri string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return err
+	}
+
+	// Verificar conexión
+	if err := client.Ping(ctx, nil); err != nil {
+		return err
+	}
+
+	Client = client
+	return nil
+}
+
+// EnsureIndexes crea los índices que usan los reportes (idempotente)
+func EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Percentiles de latencia por brazo en GET /experiments/report
+	_, err := RecsCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "experiment", Value: 1}, {Key: "arm", Value: 1}, {Key: "latency_ms", Value: 1}},
+	})
+	return err
+}
+
+func RecsCollection() *mongo.Collection {
+	return Client.Database("pcd").Collection("recommendations")
+}
+
+func LogsCollection() *mongo.Collection {
+	return Client.Database("pcd").Collection("logs")
+}
+
+func FeedbackCollection() *mongo.Collection {
+	return Client.Database("pcd").Collection("feedback")
+}
+
+func RatingsCollection() *mongo.Collection {
+	return Client.Database("pcd").Collection("ratings")
+}
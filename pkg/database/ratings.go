@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"pcd-pc4/internal/knn"
+)
+
+// -----------------------------------------------------------
+// Ratings recibidos por POST /ratings. El CSV de data/clean no
+// los tiene: el API y las herramientas offline (entrenar, evaluar,
+// barrido) los suman al cargarlo para no perderlos al reiniciar o
+// reentrenar.
+// -----------------------------------------------------------
+
+// StoredRatings devuelve los ratings de la colección en el orden en que
+// llegaron
+func StoredRatings(ctx context.Context) ([]knn.Rating, error) {
+	cur, err := RatingsCollection().Find(ctx, bson.M{},
+		options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	ratings := []knn.Rating{}
+	for cur.Next(ctx) {
+		var doc RatingDocument
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, knn.Rating{
+			UserID:    doc.UserID,
+			MovieID:   doc.MovieID,
+			Rating:    doc.Rating,
+			Timestamp: doc.TimestampUnix,
+		})
+	}
+	return ratings, cur.Err()
+}
+
+// MergeStoredRatings conecta a uri y suma a ratings (los del CSV) los
+// guardados por POST /ratings; devuelve cuántos leyó. Con uri vacío no
+// hay MongoDB y ratings queda igual.
+func MergeStoredRatings(uri string, ratings []knn.Rating) ([]knn.Rating, int, error) {
+	if uri == "" {
+		return ratings, 0, nil
+	}
+	if err := Connect(uri); err != nil {
+		return nil, 0, err
+	}
+	defer Client.Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	stored, err := StoredRatings(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return knn.MergeRatings(ratings, stored), len(stored), nil
+}
@@ -0,0 +1,399 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// -------------------- Codificación binaria compacta --------------------
+
+// Formato estilo protobuf, derivado por reflexión de los structs del
+// protocolo:
+//
+//   - cada campo no vacío de un struct se escribe como tag varint
+//     (número<<3 | tipo) seguido del valor; el struct termina con tag 0
+//   - número de campo = posición en el struct + 1 (los campos nuevos se
+//     agregan al final para no romper compatibilidad)
+//   - tipo 0: varint (bool, enteros con zigzag); tipo 1: 8 bytes (float);
+//     tipo 2: longitud + bytes (string, slice, map, struct)
+//   - un decodificador salta los campos que no conoce gracias al tipo
+//
+// Dentro de slices y maps los elementos van sin tag (el tipo lo conocen
+// ambos extremos): longitud, y luego cada elemento/par clave-valor.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+var errTruncated = errors.New("binario: datos truncados")
+
+// MarshalBinary codifica un struct (o puntero a struct) del protocolo
+func MarshalBinary(v any) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("binario: se esperaba struct, llegó %s", rv.Kind())
+	}
+	return appendStruct(nil, rv)
+}
+
+// UnmarshalBinary decodifica data sobre un puntero a struct
+func UnmarshalBinary(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("binario: se esperaba puntero a struct")
+	}
+
+	r := &byteReader{data: data}
+	return readStruct(r, rv.Elem())
+}
+
+// -------------------- Encoder --------------------
+
+func wireType(t reflect.Type) int {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return wireVarint
+	case reflect.Float32, reflect.Float64:
+		return wireFixed64
+	case reflect.Pointer:
+		return wireType(t.Elem())
+	default:
+		return wireBytes
+	}
+}
+
+func appendStruct(buf []byte, v reflect.Value) ([]byte, error) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if !t.Field(i).IsExported() || f.IsZero() {
+			continue
+		}
+		if f.Kind() == reflect.Pointer {
+			f = f.Elem()
+		}
+
+		wt := wireType(f.Type())
+		buf = binary.AppendUvarint(buf, uint64(i+1)<<3|uint64(wt))
+
+		if wt != wireBytes {
+			var err error
+			if buf, err = appendValue(buf, f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Tipo 2: longitud + cuerpo (para strings, el cuerpo son los bytes)
+		var body []byte
+		var err error
+		switch f.Kind() {
+		case reflect.String:
+			body = []byte(f.String())
+		case reflect.Struct:
+			body, err = appendStruct(nil, f)
+		default:
+			body, err = appendValue(nil, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(body)))
+		buf = append(buf, body...)
+	}
+
+	return binary.AppendUvarint(buf, 0), nil
+}
+
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.AppendUvarint(buf, v.Uint()), nil
+
+	case reflect.Float32, reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+
+	case reflect.String:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		return append(buf, v.String()...), nil
+
+	case reflect.Slice:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			var err error
+			if buf, err = appendValue(buf, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+
+	case reflect.Map:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			var err error
+			if buf, err = appendValue(buf, iter.Key()); err != nil {
+				return nil, err
+			}
+			if buf, err = appendValue(buf, iter.Value()); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+
+	case reflect.Struct:
+		body, err := appendStruct(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(body)))
+		return append(buf, body...), nil
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		return appendValue(append(buf, 1), v.Elem())
+	}
+
+	return nil, fmt.Errorf("binario: tipo no soportado %s", v.Type())
+}
+
+// -------------------- Decoder --------------------
+
+type byteReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *byteReader) uvarint() (uint64, error) {
+	x, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.pos += n
+	return x, nil
+}
+
+func (r *byteReader) varint() (int64, error) {
+	x, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.pos += n
+	return x, nil
+}
+
+func (r *byteReader) next(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func readStruct(r *byteReader, v reflect.Value) error {
+	t := v.Type()
+
+	for {
+		tag, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		if tag == 0 {
+			return nil
+		}
+
+		num, wt := int(tag>>3), int(tag&7)
+
+		// Campo desconocido o de otro tipo: saltarlo
+		if num < 1 || num > t.NumField() || !t.Field(num-1).IsExported() || wireType(t.Field(num-1).Type) != wt {
+			if err := skipField(r, wt); err != nil {
+				return err
+			}
+			continue
+		}
+
+		f := v.Field(num - 1)
+		if f.Kind() == reflect.Pointer {
+			f.Set(reflect.New(f.Type().Elem()))
+			f = f.Elem()
+		}
+
+		if wt != wireBytes {
+			if err := readValue(r, f); err != nil {
+				return err
+			}
+			continue
+		}
+
+		size, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		body, err := r.next(size)
+		if err != nil {
+			return err
+		}
+
+		sub := &byteReader{data: body}
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(string(body))
+		case reflect.Struct:
+			err = readStruct(sub, f)
+		default:
+			err = readValue(sub, f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func skipField(r *byteReader, wt int) error {
+	switch wt {
+	case wireVarint:
+		_, err := r.uvarint()
+		return err
+	case wireFixed64:
+		_, err := r.next(8)
+		return err
+	case wireBytes:
+		size, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		_, err = r.next(size)
+		return err
+	}
+	return fmt.Errorf("binario: tipo de cable desconocido %d", wt)
+}
+
+func readValue(r *byteReader, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := r.next(1)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b[0] != 0)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := r.varint()
+		if err != nil {
+			return err
+		}
+		v.SetInt(x)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		v.SetUint(x)
+
+	case reflect.Float32, reflect.Float64:
+		b, err := r.next(8)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+
+	case reflect.String:
+		n, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		b, err := r.next(n)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(b))
+
+	case reflect.Slice:
+		n, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		if n > uint64(len(r.data)-r.pos) {
+			return errTruncated // cada elemento ocupa al menos un byte
+		}
+		s := reflect.MakeSlice(v.Type(), int(n), int(n))
+		for i := 0; i < int(n); i++ {
+			if err := readValue(r, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+
+	case reflect.Map:
+		n, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		if n > uint64(len(r.data)-r.pos) {
+			return errTruncated
+		}
+		m := reflect.MakeMapWithSize(v.Type(), int(n))
+		for i := 0; i < int(n); i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := readValue(r, key); err != nil {
+				return err
+			}
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err := readValue(r, val); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, val)
+		}
+		v.Set(m)
+
+	case reflect.Struct:
+		n, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+		body, err := r.next(n)
+		if err != nil {
+			return err
+		}
+		return readStruct(&byteReader{data: body}, v)
+
+	case reflect.Pointer:
+		b, err := r.next(1)
+		if err != nil {
+			return err
+		}
+		if b[0] == 0 {
+			return nil
+		}
+		p := reflect.New(v.Type().Elem())
+		if err := readValue(r, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+
+	default:
+		return fmt.Errorf("binario: tipo no soportado %s", v.Type())
+	}
+
+	return nil
+}
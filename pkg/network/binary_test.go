@@ -0,0 +1,203 @@
+package network
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Un envelope por payload, con todos los campos no vacíos: los campos
+// vacíos no viajan y volverían como nil, no como mapa o slice vacío.
+var roundTripCases = []struct {
+	name string
+	env  Envelope
+}{
+	{"assign", Envelope{Type: MsgAssign, Assign: &AssignRequest{
+		Shards: map[string]int64{"user-0": 3, "item-1": -1},
+	}}},
+	{"assign_result", Envelope{Type: MsgAssignResult, AssignResult: &AssignResponse{
+		Missing: []string{"user-0", "item-1"},
+	}}},
+	{"load", Envelope{Type: MsgLoadShard, Load: &LoadShardRequest{
+		Shard:   "item-2",
+		Version: 7,
+		Users:   map[string]map[string]float64{"10": {"1": 4.5, "2": 0.5}, "11": {"3": 3}},
+		Kind:    ModeItem,
+		Means:   map[string]float64{"1": 3.25, "2": -0.125},
+	}}},
+	{"load_result", Envelope{Type: MsgLoadResult, LoadResult: &LoadShardResponse{Users: 1200}}},
+	{"task", Envelope{Type: MsgTask, Task: &TaskRequest{
+		Shard:         "item-0",
+		Version:       4,
+		TargetUser:    "42",
+		TargetRatings: map[string]float64{"10": 5, "11": 2.5},
+		K:             30,
+		Mode:          ModeItem,
+		RatedItems:    map[string]map[string]float64{"10": {"1": 4, "7": 1.5}},
+		N:             10,
+		Similarity:    "pearson",
+		MinOverlap:    3,
+		Significance:  50,
+		Shrinkage:     10.5,
+		ItemVersions:  map[string]int64{"10": 2, "11": 5},
+	}}},
+	{"task_result", Envelope{Type: MsgTaskResult, TaskResult: &TaskResponse{
+		PartialNeighbors: []NeighborResult{{UserID: "7", Similarity: 0.875}, {UserID: "9", Similarity: -0.25}},
+		Predictions:      []ItemScore{{MovieID: "318", Predicted: 4.75}},
+		MissingItems:     []string{"11"},
+	}}},
+	{"error", Envelope{Type: MsgError, Error: &ErrorMessage{Code: ErrCodeShardNotLoaded, Message: "shard user-1 v3"}}},
+	{"als", Envelope{Type: MsgALSStep, ALS: &ALSRequest{
+		Shard:      "user-1",
+		Version:    2,
+		Side:       ModeUser,
+		GlobalMean: 3.5,
+		Reg:        0.1,
+		Fixed:      map[string][]float64{"1": {0.5, -1, 0.25}, "2": {1e-3, 2, 0}},
+	}}},
+	{"als_result", Envelope{Type: MsgALSResult, ALSResult: &ALSResponse{
+		Vectors:      map[string][]float64{"7": {0.125, -0.5}},
+		SquaredError: 12.75,
+		Ratings:      340,
+	}}},
+	{"eval_load", Envelope{Type: MsgEvalLoad, EvalLoad: &EvalLoadRequest{
+		Split: "fold-1",
+		Total: 610,
+		Users: map[string]map[string]float64{"1": {"1": 4, "50": 5}},
+	}}},
+	{"eval_loaded", Envelope{Type: MsgEvalLoaded, EvalLoaded: &EvalLoadResponse{Users: 610}}},
+	{"eval_task", Envelope{Type: MsgEvalTask, EvalTask: &EvalTaskRequest{
+		Split:        "fold-1",
+		Test:         map[string]map[string]float64{"1": {"260": 5}},
+		Mode:         ModeUser,
+		K:            20,
+		Similarity:   "adjusted_cosine",
+		MinOverlap:   2,
+		Significance: 25,
+		Shrinkage:    5,
+		Prediction:   "mean_centered",
+		MinSupport:   3,
+		N:            10,
+		Relevance:    4,
+	}}},
+	{"eval_result", Envelope{Type: MsgEvalResult, EvalResult: &EvalTaskResponse{
+		Results: []EvalUserResult{{
+			UserID:        "1",
+			SquaredError:  1.5,
+			AbsoluteError: 2,
+			Predicted:     3,
+			Ranked:        true,
+			Precision:     0.2,
+			Recall:        0.5,
+			AP:            0.375,
+			NDCG:          0.625,
+			Hit:           true,
+			Items:         []string{"260", "1196"},
+		}},
+	}}},
+	{"update", Envelope{Type: MsgUpdate, Update: &UpdateRequest{
+		Shard:      "user-3",
+		Version:    5,
+		NewVersion: 6,
+		Vectors:    map[string]map[string]float64{"42": {"1": 3, "2": 4.5}},
+		Means:      map[string]float64{"1": 3.25},
+	}}},
+	{"update_result", Envelope{Type: MsgUpdateResult, UpdateResult: &UpdateResponse{Users: 151}}},
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, name := range CodecNames() {
+		codec, err := LookupCodec(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, tc := range roundTripCases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				want := tc.env
+				want.Version = ProtocolVersion
+				want.ID = uint64(i + 1)
+
+				var buf bytes.Buffer
+				if err := codec.NewEncoder(&buf).Encode(want); err != nil {
+					t.Fatalf("encode: %v", err)
+				}
+
+				var got Envelope
+				if err := codec.NewDecoder(&buf).Decode(&got); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("round trip:\n got  %+v\n want %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// Cada payload nuevo de Envelope necesita su caso en roundTripCases
+func TestRoundTripCasesCoverPayloads(t *testing.T) {
+	covered := map[int]bool{}
+	for _, tc := range roundTripCases {
+		v := reflect.ValueOf(tc.env)
+		for f := 0; f < v.NumField(); f++ {
+			if v.Field(f).Kind() == reflect.Pointer && !v.Field(f).IsNil() {
+				covered[f] = true
+			}
+		}
+	}
+
+	typ := reflect.TypeOf(Envelope{})
+	for f := 0; f < typ.NumField(); f++ {
+		if typ.Field(f).Type.Kind() == reflect.Pointer && !covered[f] {
+			t.Errorf("Envelope.%s no tiene caso de round trip", typ.Field(f).Name)
+		}
+	}
+}
+
+// Un decodificador viejo ignora los campos agregados al final de un
+// struct, y uno nuevo deja en cero los que no llegan
+func TestBinarySkipsUnknownFields(t *testing.T) {
+	type taskV1 struct {
+		Shard   string
+		Version int64
+	}
+
+	newer := TaskRequest{Shard: "user-0", Version: 4, TargetUser: "42", K: 30}
+	data, err := MarshalBinary(newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var old taskV1
+	if err := UnmarshalBinary(data, &old); err != nil {
+		t.Fatalf("decode v1: %v", err)
+	}
+	if old != (taskV1{Shard: "user-0", Version: 4}) {
+		t.Errorf("v1 = %+v", old)
+	}
+
+	data, err = MarshalBinary(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TaskRequest
+	if err := UnmarshalBinary(data, &got); err != nil {
+		t.Fatalf("decode v2: %v", err)
+	}
+	if !reflect.DeepEqual(got, TaskRequest{Shard: "user-0", Version: 4}) {
+		t.Errorf("v2 = %+v", got)
+	}
+}
+
+func TestBinaryTruncated(t *testing.T) {
+	data, err := MarshalBinary(roundTripCases[2].env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, len(data) / 2, len(data) - 1} {
+		var env Envelope
+		if err := UnmarshalBinary(data[:n], &env); err == nil {
+			t.Errorf("UnmarshalBinary(%d de %d bytes) sin error", n, len(data))
+		}
+	}
+}
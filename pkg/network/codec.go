@@ -0,0 +1,205 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// -------------------- Codecs del protocolo de nodos --------------------
+
+// Codec define cómo viajan los envelopes después del handshake. Cada
+// conexión crea su propio Encoder/Decoder (pueden tener estado, como gob).
+type Codec interface {
+	Name() string
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+type Encoder interface {
+	Encode(env Envelope) error
+}
+
+type Decoder interface {
+	Decode(env *Envelope) error
+}
+
+const (
+	CodecGob    = "gob"    // frames con gob persistente (sólo clientes Go)
+	CodecJSON   = "jsonl"  // un envelope JSON por línea
+	CodecBinary = "binary" // frames con codificación binaria compacta
+)
+
+var codecs = map[string]Codec{
+	CodecGob:    gobCodec{},
+	CodecJSON:   jsonCodec{},
+	CodecBinary: binaryCodec{},
+}
+
+// DefaultCodecs es el orden de preferencia si no se configura otro
+var DefaultCodecs = []string{CodecBinary, CodecGob, CodecJSON}
+
+// LookupCodec busca un codec por nombre
+func LookupCodec(name string) (Codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("codec desconocido %q (disponibles: %v)", name, CodecNames())
+	}
+	return c, nil
+}
+
+// ParseCodecs interpreta una lista separada por comas ("binary,gob");
+// vacía equivale a DefaultCodecs.
+func ParseCodecs(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return DefaultCodecs, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if _, err := LookupCodec(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func CodecNames() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// -------------------- gob --------------------
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return CodecGob }
+
+// El gob es persistente por conexión: la información de tipos viaja sólo
+// en el primer frame que la necesita.
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	e := &gobEncoder{w: w}
+	e.enc = gob.NewEncoder(&e.buf)
+	return e
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	d := &gobDecoder{r: r}
+	d.dec = gob.NewDecoder(&d.buf)
+	return d
+}
+
+type gobEncoder struct {
+	w   io.Writer
+	buf bytes.Buffer
+	enc *gob.Encoder
+}
+
+func (e *gobEncoder) Encode(env Envelope) error {
+	e.buf.Reset()
+	if err := e.enc.Encode(env); err != nil {
+		return err
+	}
+	return WriteFrame(e.w, e.buf.Bytes())
+}
+
+type gobDecoder struct {
+	r   io.Reader
+	buf bytes.Buffer
+	dec *gob.Decoder
+}
+
+func (d *gobDecoder) Decode(env *Envelope) error {
+	payload, err := ReadFrame(d.r)
+	if err != nil {
+		return err
+	}
+	d.buf.Reset()
+	d.buf.Write(payload)
+	return d.dec.Decode(env)
+}
+
+// -------------------- JSON lines --------------------
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return CodecJSON }
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return &jsonEncoder{w: w}
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return jsonDecoder{dec: json.NewDecoder(r)}
+}
+
+type jsonEncoder struct {
+	w io.Writer
+}
+
+// Encode escribe la línea completa de una vez para no intercalar mensajes
+func (e *jsonEncoder) Encode(env Envelope) error {
+	line, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(line, '\n'))
+	return err
+}
+
+type jsonDecoder struct {
+	dec *json.Decoder
+}
+
+func (d jsonDecoder) Decode(env *Envelope) error {
+	return d.dec.Decode(env)
+}
+
+// -------------------- binario compacto --------------------
+
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string { return CodecBinary }
+
+func (binaryCodec) NewEncoder(w io.Writer) Encoder {
+	return binaryEncoder{w: w}
+}
+
+func (binaryCodec) NewDecoder(r io.Reader) Decoder {
+	return binaryDecoder{r: r}
+}
+
+type binaryEncoder struct {
+	w io.Writer
+}
+
+func (e binaryEncoder) Encode(env Envelope) error {
+	payload, err := MarshalBinary(env)
+	if err != nil {
+		return err
+	}
+	return WriteFrame(e.w, payload)
+}
+
+type binaryDecoder struct {
+	r io.Reader
+}
+
+func (d binaryDecoder) Decode(env *Envelope) error {
+	payload, err := ReadFrame(d.r)
+	if err != nil {
+		return err
+	}
+	*env = Envelope{}
+	return UnmarshalBinary(payload, env)
+}
@@ -0,0 +1,48 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// -------------------- Framing --------------------
+
+// Cada frame es [longitud uint32 big-endian][payload]. El límite evita
+// reservar memoria absurda si llega basura por el socket.
+const MaxFrameSize = 512 << 20
+
+func WriteFrame(w io.Writer, payload []byte) error {
+	if len(payload) > MaxFrameSize {
+		return fmt.Errorf("frame de %d bytes excede el máximo", len(payload))
+	}
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(payload)))
+
+	// Una sola escritura para que el frame no se intercale con otro
+	buf := make([]byte, 0, len(header)+len(payload))
+	buf = append(buf, header[:]...)
+	buf = append(buf, payload...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("frame de %d bytes excede el máximo", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,213 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// -------------------- Handshake --------------------
+
+// El handshake viaja en JSON (un frame por mensaje) para no depender de
+// gob: cualquier cliente puede leerlo y saber por qué fue rechazado.
+// Orden: el nodo manda un Challenge con un nonce nuevo, el cliente
+// responde con su Hello (firmado sobre ese nonce si hay secreto) y el
+// nodo cierra con el HelloAck. Con secreto, el ack también va firmado y
+// los frames siguientes llevan HMAC con la clave de la sesión.
+
+const handshakeTimeout = 5 * time.Second
+
+var ErrIncompatibleVersion = errors.New("versión de protocolo incompatible")
+
+// Challenge es lo primero que manda el nodo. Version va en la misma
+// posición JSON que en HelloAck para que un cliente anterior lo lea como
+// un rechazo por versión.
+type Challenge struct {
+	Version int    `json:"version"`
+	Nonce   string `json:"nonce"`
+}
+
+// Hello lo envía el cliente con los codecs que habla, en orden de
+// preferencia; sin codecs se asume gob. Auth es la firma HMAC del hello
+// (ligada al nonce del nodo y al propio) cuando hay secreto compartido.
+type Hello struct {
+	Version int      `json:"version"`
+	Codecs  []string `json:"codecs,omitempty"`
+	Nonce   string   `json:"nonce,omitempty"`
+	Auth    string   `json:"auth,omitempty"`
+}
+
+// signedPayload es lo que cubre la firma del hello
+func (h Hello) signedPayload() string {
+	return fmt.Sprintf("hello|%d|%s", h.Version, strings.Join(h.Codecs, ","))
+}
+
+// ClientConfig: lo que el cliente ofrece en el handshake
+type ClientConfig struct {
+	Codecs []string       // en orden de preferencia
+	Auth   *Authenticator // nil = sin firma
+}
+
+// ServerConfig: lo que el nodo acepta en el handshake
+type ServerConfig struct {
+	Codecs []string       // vacío = DefaultCodecs
+	Auth   *Authenticator // nil = no se exige firma
+}
+
+// HelloAck cierra el handshake; con secreto, Auth prueba que el nodo
+// también lo conoce
+type HelloAck struct {
+	Version  int    `json:"version"`
+	Accepted bool   `json:"accepted"`
+	Codec    string `json:"codec,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (a HelloAck) signedPayload() string {
+	return "ack|" + a.Codec
+}
+
+// ClientHandshake anuncia versión y codecs, y devuelve el codec elegido
+// por el nodo y la conexión sobre la que seguir (con HMAC por frame si
+// hay secreto).
+func ClientHandshake(conn net.Conn, cfg ClientConfig) (net.Conn, Codec, error) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	var challenge Challenge
+	if err := readJSONFrame(conn, &challenge); err != nil {
+		return nil, nil, err
+	}
+
+	hello := Hello{Version: ProtocolVersion, Codecs: cfg.Codecs, Nonce: newNonce()}
+	hello.Auth = cfg.Auth.prove(hello.signedPayload(), challenge.Nonce, hello.Nonce)
+
+	if err := writeJSONFrame(conn, hello); err != nil {
+		return nil, nil, err
+	}
+
+	var ack HelloAck
+	if err := readJSONFrame(conn, &ack); err != nil {
+		return nil, nil, err
+	}
+
+	if !ack.Accepted {
+		if strings.HasPrefix(ack.Error, ErrCodeUnauthorized) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnauthorized, ack.Error)
+		}
+		if ack.Version != ProtocolVersion {
+			return nil, nil, fmt.Errorf("%w: cliente v%d, nodo v%d: %s", ErrIncompatibleVersion, ProtocolVersion, ack.Version, ack.Error)
+		}
+		return nil, nil, fmt.Errorf("handshake rechazado: %s", ack.Error)
+	}
+
+	// Un nodo sin el secreto (o sin secreto configurado) no puede firmar
+	if err := cfg.Auth.checkProof(ack.signedPayload(), challenge.Nonce, hello.Nonce, ack.Auth); err != nil {
+		return nil, nil, fmt.Errorf("%w: el nodo no probó conocer el secreto", err)
+	}
+
+	if ack.Codec == "" {
+		ack.Codec = CodecGob
+	}
+	codec, err := LookupCodec(ack.Codec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg.Auth.seal(conn, challenge.Nonce, hello.Nonce, false), codec, nil
+}
+
+// ServerHandshake valida la firma y la versión del cliente y elige el
+// primer codec de su lista que el nodo acepte. Si no hay acuerdo le
+// responde con el motivo y devuelve error para cerrar la conexión.
+func ServerHandshake(conn net.Conn, cfg ServerConfig) (net.Conn, Codec, error) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	challenge := Challenge{Version: ProtocolVersion, Nonce: newNonce()}
+	if err := writeJSONFrame(conn, challenge); err != nil {
+		return nil, nil, err
+	}
+
+	var hello Hello
+	if err := readJSONFrame(conn, &hello); err != nil {
+		return nil, nil, err
+	}
+
+	ack := HelloAck{Version: ProtocolVersion}
+
+	authErr := cfg.Auth.checkProof(hello.signedPayload(), challenge.Nonce, hello.Nonce, hello.Auth)
+
+	switch {
+	case authErr != nil:
+		ack.Error = fmt.Sprintf("%s: %v", ErrCodeUnauthorized, authErr)
+	case hello.Version != ProtocolVersion:
+		ack.Error = fmt.Sprintf("%s: se requiere v%d", ErrCodeVersion, ProtocolVersion)
+	default:
+		ack.Codec = chooseCodec(hello.Codecs, cfg.Codecs)
+		if ack.Codec == "" {
+			ack.Error = fmt.Sprintf("%s: ningún codec en común con %v", ErrCodeBadRequest, cfg.Codecs)
+		}
+	}
+	ack.Accepted = ack.Error == ""
+	if ack.Accepted {
+		ack.Auth = cfg.Auth.prove(ack.signedPayload(), challenge.Nonce, hello.Nonce)
+	}
+
+	if err := writeJSONFrame(conn, ack); err != nil {
+		return nil, nil, err
+	}
+
+	if !ack.Accepted {
+		if authErr != nil {
+			return nil, nil, authErr
+		}
+		if hello.Version != ProtocolVersion {
+			return nil, nil, fmt.Errorf("%w: cliente v%d", ErrIncompatibleVersion, hello.Version)
+		}
+		return nil, nil, fmt.Errorf("handshake rechazado: %s", ack.Error)
+	}
+
+	codec, err := LookupCodec(ack.Codec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg.Auth.seal(conn, challenge.Nonce, hello.Nonce, true), codec, nil
+}
+
+func chooseCodec(offered, supported []string) string {
+	if len(offered) == 0 {
+		offered = []string{CodecGob}
+	}
+	if len(supported) == 0 {
+		supported = DefaultCodecs
+	}
+
+	for _, name := range offered {
+		for _, s := range supported {
+			if name == s {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func writeJSONFrame(conn net.Conn, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return WriteFrame(conn, payload)
+}
+
+func readJSONFrame(conn net.Conn, v any) error {
+	payload, err := ReadFrame(conn)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
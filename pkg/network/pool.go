@@ -0,0 +1,266 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// -------------------- Conexiones persistentes --------------------
+
+var ErrConnClosed = errors.New("conexión con el nodo cerrada")
+
+// Conn es una conexión larga hacia un nodo sobre la que viajan varias
+// peticiones a la vez; cada respuesta vuelve a su llamador por su ID.
+type Conn struct {
+	conn  net.Conn
+	codec Codec
+	enc   Encoder
+	dec   Decoder
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan Envelope
+	err     error // != nil una vez cerrada
+}
+
+// Dial abre una conexión con un nodo y realiza el handshake (versión,
+// firma y codec)
+func Dial(ctx context.Context, dial func(ctx context.Context, network, addr string) (net.Conn, error), addr string, cfg ClientConfig) (*Conn, error) {
+	raw, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, codec, err := ClientHandshake(raw, cfg)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	return NewConn(conn, codec), nil
+}
+
+// NewConn envuelve una conexión que ya completó el handshake
+func NewConn(c net.Conn, codec Codec) *Conn {
+	mc := &Conn{
+		conn:    c,
+		codec:   codec,
+		enc:     codec.NewEncoder(c),
+		dec:     codec.NewDecoder(c),
+		pending: make(map[uint64]chan Envelope),
+	}
+	go mc.readLoop()
+	return mc
+}
+
+// Codec devuelve el codec negociado con el nodo
+func (c *Conn) Codec() string {
+	return c.codec.Name()
+}
+
+// Call envía msg y espera su respuesta o a que venza ctx. Si el nodo
+// responde con MsgError se devuelve un *RemoteError.
+func (c *Conn) Call(ctx context.Context, msg Envelope) (Envelope, error) {
+	ch := make(chan Envelope, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return Envelope{}, c.err
+	}
+	c.nextID++
+	msg.ID = c.nextID
+	c.pending[msg.ID] = ch
+	c.mu.Unlock()
+
+	if err := c.write(ctx, msg); err != nil {
+		c.forget(msg.ID)
+		return Envelope{}, err
+	}
+
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return Envelope{}, c.closedErr()
+		}
+		if reply.Type == MsgError {
+			remote := &RemoteError{Code: ErrCodeBadRequest, Message: "error sin detalle"}
+			if reply.Error != nil {
+				remote.Code, remote.Message = reply.Error.Code, reply.Error.Message
+			}
+			return Envelope{}, remote
+		}
+		return reply, nil
+	case <-ctx.Done():
+		c.forget(msg.ID)
+		return Envelope{}, ctx.Err()
+	}
+}
+
+func (c *Conn) write(ctx context.Context, msg Envelope) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetWriteDeadline(deadline)
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+
+	msg.Version = ProtocolVersion
+	if err := c.enc.Encode(msg); err != nil {
+		// El stream queda inconsistente tras un error de escritura
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Conn) readLoop() {
+	for {
+		var reply Envelope
+		if err := c.dec.Decode(&reply); err != nil {
+			c.fail(err)
+			return
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[reply.ID]
+		delete(c.pending, reply.ID)
+		c.mu.Unlock()
+
+		// Si el llamador ya se rindió (timeout) la respuesta se descarta
+		if ok {
+			ch <- reply
+		}
+	}
+}
+
+// Closed indica si la conexión ya no admite llamadas
+func (c *Conn) Closed() bool {
+	return c.closedErr() != nil
+}
+
+func (c *Conn) Close() error {
+	c.fail(ErrConnClosed)
+	return nil
+}
+
+func (c *Conn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+	c.err = fmt.Errorf("%w: %v", ErrConnClosed, err)
+	c.conn.Close()
+
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+func (c *Conn) forget(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// -------------------- Pool de conexiones por nodo --------------------
+
+// Pool mantiene una conexión multiplexada por dirección y la vuelve a
+// abrir cuando se cae.
+type Pool struct {
+	// Dial abre la conexión subyacente (reemplazable, p. ej. para TLS)
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Codecs en orden de preferencia y firma para el handshake
+	Codecs []string
+	Auth   *Authenticator
+
+	mu    sync.Mutex
+	conns map[string]*Conn
+}
+
+func NewPool() *Pool {
+	var d net.Dialer
+	return &Pool{
+		Dial:   d.DialContext,
+		Codecs: DefaultCodecs,
+		conns:  make(map[string]*Conn),
+	}
+}
+
+// Call envía msg al nodo addr reutilizando su conexión
+func (p *Pool) Call(ctx context.Context, addr string, msg Envelope) (Envelope, error) {
+	conn, err := p.get(ctx, addr)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return conn.Call(ctx, msg)
+}
+
+func (p *Pool) get(ctx context.Context, addr string) (*Conn, error) {
+	p.mu.Lock()
+	c, ok := p.conns[addr]
+	p.mu.Unlock()
+
+	if ok && !c.Closed() {
+		return c, nil
+	}
+
+	// Conectar fuera del lock para no frenar las llamadas a otros nodos
+	fresh, err := Dial(ctx, p.Dial, addr, ClientConfig{Codecs: p.Codecs, Auth: p.Auth})
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Otra goroutine pudo reconectar mientras tanto
+	if c, ok := p.conns[addr]; ok && !c.Closed() {
+		fresh.Close()
+		return c, nil
+	}
+
+	p.conns[addr] = fresh
+	return fresh, nil
+}
+
+// Drop cierra la conexión con un nodo que salió del cluster
+func (p *Pool) Drop(addr string) {
+	p.mu.Lock()
+	c, ok := p.conns[addr]
+	delete(p.conns, addr)
+	p.mu.Unlock()
+
+	if ok {
+		c.Close()
+	}
+}
+
+func (p *Pool) Close() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[string]*Conn)
+	p.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
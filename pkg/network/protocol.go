@@ -1,45 +1,295 @@
-package network
-
-import (
-	"encoding/gob"
-	"net"
-)
-
-// -------------------- Tipos de Mensaje --------------------
-
-type TaskRequest struct {
-	TargetUser string                        // usuario al que queremos recomendar
-	UserChunk  map[string]map[string]float64 // subset de usuarios asignados al nodo
-	K          int                           // vecinos K
-}
-
-type TaskResponse struct {
-	PartialNeighbors []NeighborResult // vecinos parciales
-}
-
-type NeighborResult struct {
-	UserID     string
-	Similarity float64
-}
-
-// -------------------- Utilidades --------------------
-
-// Enviar mensaje genérico
-func Send(conn net.Conn, v any) error {
-	enc := gob.NewEncoder(conn)
-	return enc.Encode(v)
-}
-
-// Recibir mensaje genérico
-func Receive(conn net.Conn, v any) error {
-	dec := gob.NewDecoder(conn)
-	return dec.Decode(v)
-}
-
-func init() {
-	// Registrar tipos para que gob pueda codificarlos
-	gob.Register(TaskRequest{})
-	gob.Register(TaskResponse{})
-	gob.Register(NeighborResult{})
-	gob.Register(map[string]map[string]float64{})
-}
+package network
+
+import (
+	"encoding/gob"
+	"fmt"
+)
+
+// -------------------- Versión del protocolo --------------------
+
+// ProtocolVersion se negocia en el handshake; un nodo rechaza clientes
+// con una versión distinta antes de procesar cualquier mensaje. La v2
+// empieza el handshake por el Challenge del nodo.
+const ProtocolVersion = 2
+
+// -------------------- Tipos de Mensaje --------------------
+
+const (
+	MsgAssign    = "assign"     // el API indica qué shards debe mantener el nodo
+	MsgLoadShard = "load_shard" // el API envía los usuarios de un shard
+	MsgTask      = "task"       // búsqueda de vecinos sobre un shard cargado
+	MsgALSStep   = "als_step"   // entrenamiento ALS: resolver los vectores de un shard
+	MsgEvalLoad  = "eval_load"  // evaluación offline: parte del split de train
+	MsgEvalTask  = "eval_task"  // evaluación offline: métricas de un lote de usuarios de test
+	MsgUpdate    = "update"     // ratings nuevos: reemplazar vectores de un shard cargado
+
+	// Respuestas
+	MsgAssignResult = "assign_result"
+	MsgLoadResult   = "load_result"
+	MsgTaskResult   = "task_result"
+	MsgALSResult    = "als_result"
+	MsgEvalLoaded   = "eval_loaded"
+	MsgEvalResult   = "eval_result"
+	MsgUpdateResult = "update_result"
+	MsgError        = "error"
+)
+
+// Envelope es la unidad que viaja en cada frame, en ambos sentidos. ID
+// permite tener varias peticiones en vuelo sobre la misma conexión; la
+// respuesta lleva el mismo ID y, según Type, uno de los payloads.
+//
+// Los tags json son para el codec jsonl; el codec binario numera los
+// campos por posición, así que los campos nuevos van siempre al final.
+type Envelope struct {
+	Version uint16 `json:"version"`
+	Type    string `json:"type"`
+	ID      uint64 `json:"id"`
+
+	Assign       *AssignRequest     `json:"assign,omitempty"`
+	AssignResult *AssignResponse    `json:"assign_result,omitempty"`
+	Load         *LoadShardRequest  `json:"load,omitempty"`
+	LoadResult   *LoadShardResponse `json:"load_result,omitempty"`
+	Task         *TaskRequest       `json:"task,omitempty"`
+	TaskResult   *TaskResponse      `json:"task_result,omitempty"`
+	Error        *ErrorMessage      `json:"error,omitempty"`
+	ALS          *ALSRequest        `json:"als,omitempty"`
+	ALSResult    *ALSResponse       `json:"als_result,omitempty"`
+	EvalLoad     *EvalLoadRequest   `json:"eval_load,omitempty"`
+	EvalLoaded   *EvalLoadResponse  `json:"eval_loaded,omitempty"`
+	EvalTask     *EvalTaskRequest   `json:"eval_task,omitempty"`
+	EvalResult   *EvalTaskResponse  `json:"eval_result,omitempty"`
+	Update       *UpdateRequest     `json:"update,omitempty"`
+	UpdateResult *UpdateResponse    `json:"update_result,omitempty"`
+}
+
+// -------------------- Errores explícitos --------------------
+
+const (
+	ErrCodeBadRequest     = "bad_request"      // mensaje desconocido o incompleto
+	ErrCodeUnknownUser    = "unknown_user"     // el usuario objetivo no tiene ratings
+	ErrCodeShardNotLoaded = "shard_not_loaded" // el nodo no tiene ese shard/versión
+	ErrCodeOverloaded     = "overloaded"       // el nodo rechaza trabajo por carga
+	ErrCodeVersion        = "incompatible_version"
+	ErrCodeUnauthorized   = "unauthorized" // handshake sin firma válida
+)
+
+type ErrorMessage struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// RemoteError es el error que devuelve Conn.Call cuando el nodo responde
+// con MsgError; el llamador decide según Code (reintentar, recargar, ...).
+type RemoteError struct {
+	Code    string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("nodo: %s: %s", e.Code, e.Message)
+}
+
+// ErrorReply arma la respuesta de error para una petición
+func ErrorReply(code, format string, args ...any) Envelope {
+	return Envelope{
+		Type:  MsgError,
+		Error: &ErrorMessage{Code: code, Message: fmt.Sprintf(format, args...)},
+	}
+}
+
+// -------------------- Modos de filtrado colaborativo --------------------
+
+// Un shard contiene vectores de usuarios (user-based) o de ítems
+// (item-based); las tareas indican sobre cuál de los dos trabajan.
+const (
+	ModeUser = "user" // shard usuario -> película -> rating
+	ModeItem = "item" // shard película -> usuario -> rating
+)
+
+// -------------------- Payloads --------------------
+
+// AssignRequest: el nodo descarta los shards que no figuren (o cuya
+// versión cambió) y responde cuáles le faltan.
+type AssignRequest struct {
+	Shards map[string]int64 `json:"shards"` // id del shard -> versión esperada
+}
+
+type AssignResponse struct {
+	Missing []string `json:"missing"` // shards que el API debe enviar con MsgLoadShard
+}
+
+type LoadShardRequest struct {
+	Shard   string                        `json:"shard"`   // id del shard (un nodo puede tener varios)
+	Version int64                         `json:"version"` // versión del contenido del shard
+	Users   map[string]map[string]float64 `json:"users"`   // subset de usuarios (o de ítems) del shard
+	Kind    string                        `json:"kind"`    // ModeUser o ModeItem ("" = ModeUser)
+
+	// Media global de cada dimensión de los vectores (películas en shards
+	// de usuarios, usuarios en shards de ítems) para el coseno ajustado
+	Means map[string]float64 `json:"means,omitempty"`
+}
+
+type LoadShardResponse struct {
+	Users int `json:"users"` // usuarios que el nodo mantiene en memoria
+}
+
+type TaskRequest struct {
+	Shard         string             `json:"shard"`          // shard sobre el que buscar vecinos
+	Version       int64              `json:"version"`        // versión esperada del shard
+	TargetUser    string             `json:"target_user"`    // usuario al que queremos recomendar
+	TargetRatings map[string]float64 `json:"target_ratings"` // vector de ratings del usuario objetivo
+	K             int                `json:"k"`              // vecinos K
+
+	// Item-based: vectores (usuario -> rating) de las películas que el
+	// usuario objetivo calificó que el nodo pidió (ver ItemVersions) y
+	// cuántas predicciones devolver
+	Mode       string                        `json:"mode"` // ModeUser o ModeItem ("" = ModeUser)
+	RatedItems map[string]map[string]float64 `json:"rated_items,omitempty"`
+	N          int                           `json:"n,omitempty"`
+
+	Similarity string `json:"similarity"` // medida de knn.NewSimilarity ("" = coseno)
+
+	// Filtro de vecinos aplicado en el nodo antes del top K parcial
+	MinOverlap   int     `json:"min_overlap,omitempty"`  // co-calificados mínimos
+	Significance int     `json:"significance,omitempty"` // N de la ponderación por significancia
+	Shrinkage    float64 `json:"shrinkage,omitempty"`    // λ del shrinkage n/(n+λ)
+
+	// Item-based: versión del vector de cada película calificada. El nodo
+	// usa los vectores de su shard o de su caché y responde MissingItems
+	// con los que no tiene; el API repite la tarea sólo con esos en
+	// RatedItems.
+	ItemVersions map[string]int64 `json:"item_versions,omitempty"`
+}
+
+type TaskResponse struct {
+	PartialNeighbors []NeighborResult `json:"partial_neighbors"`     // vecinos parciales
+	Predictions      []ItemScore      `json:"predictions,omitempty"` // item-based: mejores películas del shard
+
+	MissingItems []string `json:"missing_items,omitempty"` // item-based: vectores que el nodo necesita
+}
+
+type NeighborResult struct {
+	UserID     string  `json:"user_id"`
+	Similarity float64 `json:"similarity"`
+}
+
+type ItemScore struct {
+	MovieID   string  `json:"movie_id"`
+	Predicted float64 `json:"predicted"`
+}
+
+// ALSRequest: con los vectores del otro lado fijos, el nodo resuelve el
+// vector [factores..., sesgo] de cada usuario (Side = ModeUser, sobre su
+// shard de usuarios) o de cada película (ModeItem, shard de ítems).
+type ALSRequest struct {
+	Shard      string               `json:"shard"`
+	Version    int64                `json:"version"`
+	Side       string               `json:"side"`
+	GlobalMean float64              `json:"global_mean"`
+	Reg        float64              `json:"reg"`
+	Fixed      map[string][]float64 `json:"fixed"` // sólo los ids que aparecen en el shard
+}
+
+type ALSResponse struct {
+	Vectors      map[string][]float64 `json:"vectors"`
+	SquaredError float64              `json:"squared_error"` // error de entrenamiento del shard tras resolver
+	Ratings      int                  `json:"ratings"`
+}
+
+// UpdateRequest: el API reemplaza los vectores completos de los usuarios
+// (o películas) que recibieron ratings. Sólo se aplica si el nodo tiene
+// el shard en Version; si no, responde shard_not_loaded y el API lo
+// vuelve a cargar entero.
+type UpdateRequest struct {
+	Shard      string                        `json:"shard"`
+	Version    int64                         `json:"version"`     // versión que debe tener el nodo
+	NewVersion int64                         `json:"new_version"` // versión tras aplicar los cambios
+	Vectors    map[string]map[string]float64 `json:"vectors"`
+
+	// Medias de la otra dimensión que cambiaron (ver LoadShardRequest)
+	Means map[string]float64 `json:"means,omitempty"`
+}
+
+type UpdateResponse struct {
+	Users int `json:"users"` // vectores del shard tras actualizar
+}
+
+// EvalLoadRequest: el coordinador de la evaluación envía el split de
+// train completo a cada nodo, en partes de hasta unos miles de usuarios.
+// Un Split nuevo descarta el anterior; el nodo acepta tareas cuando
+// tiene Total usuarios.
+type EvalLoadRequest struct {
+	Split string                        `json:"split"`
+	Total int                           `json:"total"`
+	Users map[string]map[string]float64 `json:"users"`
+}
+
+type EvalLoadResponse struct {
+	Users int `json:"users"` // usuarios del split recibidos hasta ahora
+}
+
+// EvalTaskRequest: un lote de usuarios de test con sus ratings retenidos
+// y la configuración del KNN a evaluar (mismos campos que TaskRequest).
+type EvalTaskRequest struct {
+	Split string                        `json:"split"`
+	Test  map[string]map[string]float64 `json:"test"`
+
+	Mode         string  `json:"mode"` // ModeUser o ModeItem
+	K            int     `json:"k"`
+	Similarity   string  `json:"similarity"`
+	MinOverlap   int     `json:"min_overlap,omitempty"`
+	Significance int     `json:"significance,omitempty"`
+	Shrinkage    float64 `json:"shrinkage,omitempty"`
+	Prediction   string  `json:"prediction,omitempty"`
+	MinSupport   int     `json:"min_support,omitempty"`
+
+	N         int     `json:"n"`         // tamaño del top evaluado
+	Relevance float64 `json:"relevance"` // rating mínimo de una película relevante
+}
+
+type EvalTaskResponse struct {
+	Results []EvalUserResult `json:"results"`
+}
+
+// EvalUserResult: lo que aporta un usuario de test a las métricas. Los
+// acumulados de error permiten agregar resultados calculados en
+// distintas máquinas.
+type EvalUserResult struct {
+	UserID string `json:"user_id"`
+
+	SquaredError  float64 `json:"squared_error"`
+	AbsoluteError float64 `json:"absolute_error"`
+	Predicted     int     `json:"predicted"` // ratings de test con predicción
+
+	Ranked    bool     `json:"ranked"` // tenía películas relevantes en test
+	Precision float64  `json:"precision"`
+	Recall    float64  `json:"recall"`
+	AP        float64  `json:"ap"`
+	NDCG      float64  `json:"ndcg"`
+	Hit       bool     `json:"hit"`
+	Items     []string `json:"items"` // películas recomendadas (cobertura)
+}
+
+func init() {
+	// Registrar tipos para que gob pueda codificarlos
+	gob.Register(Envelope{})
+	gob.Register(ErrorMessage{})
+	gob.Register(AssignRequest{})
+	gob.Register(AssignResponse{})
+	gob.Register(LoadShardRequest{})
+	gob.Register(LoadShardResponse{})
+	gob.Register(TaskRequest{})
+	gob.Register(TaskResponse{})
+	gob.Register(NeighborResult{})
+	gob.Register(ItemScore{})
+	gob.Register(ALSRequest{})
+	gob.Register(ALSResponse{})
+	gob.Register(EvalLoadRequest{})
+	gob.Register(EvalLoadResponse{})
+	gob.Register(EvalTaskRequest{})
+	gob.Register(EvalTaskResponse{})
+	gob.Register(EvalUserResult{})
+	gob.Register(UpdateRequest{})
+	gob.Register(UpdateResponse{})
+	gob.Register(map[string]map[string]float64{})
+}
@@ -0,0 +1,293 @@
+package network
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -------------------- TLS mutuo --------------------
+
+// TLSFromEnv arma la configuración TLS a partir de <prefix>_TLS_CERT,
+// <prefix>_TLS_KEY y <prefix>_TLS_CA. Cada variable puede traer la ruta a
+// un archivo PEM o el PEM mismo. Devuelve nil si TLS no está configurado.
+// El mismo certificado sirve como servidor (nodo) o cliente (API), y
+// ambos extremos exigen que el otro presente uno firmado por la CA.
+func TLSFromEnv(prefix string, server bool) (*tls.Config, error) {
+	certVar, keyVar, caVar := prefix+"_TLS_CERT", prefix+"_TLS_KEY", prefix+"_TLS_CA"
+
+	if os.Getenv(certVar) == "" && os.Getenv(keyVar) == "" && os.Getenv(caVar) == "" {
+		return nil, nil
+	}
+
+	certPEM, err := loadPEM(certVar)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := loadPEM(keyVar)
+	if err != nil {
+		return nil, err
+	}
+	caPEM, err := loadPEM(caVar)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("certificado TLS inválido: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("%s no contiene certificados válidos", caVar)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if server {
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+func loadPEM(name string) ([]byte, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, fmt.Errorf("%s es obligatorio cuando se usa TLS", name)
+	}
+	if strings.HasPrefix(strings.TrimSpace(v), "-----BEGIN") {
+		return []byte(v), nil
+	}
+
+	data, err := os.ReadFile(v)
+	if err != nil {
+		return nil, fmt.Errorf("leyendo %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// -------------------- Secreto compartido (HMAC) --------------------
+
+var ErrUnauthorized = errors.New("firma HMAC inválida")
+
+// Margen aceptado entre relojes y vida de un nonce ya visto
+const signatureMaxSkew = 2 * time.Minute
+
+// Authenticator firma y verifica mensajes con HMAC-SHA256 sobre un
+// secreto compartido. Sign/Verify sirven para peticiones sueltas (registro
+// de nodos): llevan timestamp y nonce, y un nonce repetido dentro de la
+// ventana se rechaza. Las conexiones con nodos no usan esa ventana: el
+// handshake firma un nonce que emite el nodo y de ahí sale la clave que
+// autentica cada frame (ver sealedConn).
+type Authenticator struct {
+	secret []byte
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewAuthenticator devuelve nil si secret está vacío (autenticación
+// desactivada); los métodos aceptan receptor nil.
+func NewAuthenticator(secret []byte) *Authenticator {
+	if len(secret) == 0 {
+		return nil
+	}
+	return &Authenticator{secret: secret, seen: make(map[string]time.Time)}
+}
+
+// SecretFromEnv lee <prefix>_SECRET o el archivo de <prefix>_SECRET_FILE
+func SecretFromEnv(prefix string) ([]byte, error) {
+	if v := os.Getenv(prefix + "_SECRET"); v != "" {
+		return []byte(v), nil
+	}
+	if path := os.Getenv(prefix + "_SECRET_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimSpace(string(data))), nil
+	}
+	return nil, nil
+}
+
+// Sign firma payload; devuelve "timestamp:nonce:mac" ("" sin secreto)
+func (a *Authenticator) Sign(payload string) string {
+	if a == nil {
+		return ""
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	n := newNonce()
+	return ts + ":" + n + ":" + a.mac(ts, n, payload)
+}
+
+// Verify comprueba la firma de payload; con receptor nil acepta todo
+func (a *Authenticator) Verify(payload, signature string) error {
+	if a == nil {
+		return nil
+	}
+
+	parts := strings.SplitN(signature, ":", 3)
+	if len(parts) != 3 {
+		return ErrUnauthorized
+	}
+	ts, nonce, mac := parts[0], parts[1], parts[2]
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrUnauthorized
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > signatureMaxSkew || skew < -signatureMaxSkew {
+		return fmt.Errorf("%w: timestamp fuera de ventana", ErrUnauthorized)
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(a.mac(ts, nonce, payload))) {
+		return ErrUnauthorized
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for n, at := range a.seen {
+		if now.Sub(at) > 2*signatureMaxSkew {
+			delete(a.seen, n)
+		}
+	}
+	if _, replay := a.seen[nonce]; replay {
+		return fmt.Errorf("%w: nonce repetido", ErrUnauthorized)
+	}
+	a.seen[nonce] = now
+
+	return nil
+}
+
+func (a *Authenticator) mac(ts, nonce, payload string) string {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write([]byte(ts + "|" + nonce + "|" + payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func newNonce() string {
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+	return hex.EncodeToString(nonce)
+}
+
+// -------------------- Sesión autenticada --------------------
+
+// prove firma payload ligado a los nonces de ambos extremos; como el del
+// nodo es nuevo en cada conexión, una firma capturada no sirve para otra
+func (a *Authenticator) prove(payload, serverNonce, clientNonce string) string {
+	if a == nil {
+		return ""
+	}
+	return a.mac("conn|"+serverNonce, clientNonce, payload)
+}
+
+func (a *Authenticator) checkProof(payload, serverNonce, clientNonce, proof string) error {
+	if a == nil {
+		return nil
+	}
+	if !hmac.Equal([]byte(proof), []byte(a.prove(payload, serverNonce, clientNonce))) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// seal envuelve la conexión para que cada frame posterior al handshake
+// lleve su HMAC; sin secreto devuelve conn tal cual. La clave de sesión
+// sale de los dos nonces, así que no se repite entre conexiones.
+func (a *Authenticator) seal(conn net.Conn, serverNonce, clientNonce string, server bool) net.Conn {
+	if a == nil {
+		return conn
+	}
+
+	h := hmac.New(sha256.New, a.secret)
+	h.Write([]byte("session|" + serverNonce + "|" + clientNonce))
+	key := h.Sum(nil)
+
+	c := &sealedConn{Conn: conn, key: key, send: 'c', recv: 's'}
+	if server {
+		c.send, c.recv = 's', 'c'
+	}
+	return c
+}
+
+// sealedConn manda cada Write como un frame [datos][HMAC-SHA256] y sólo
+// entrega a Read datos cuyo HMAC coincide. El HMAC cubre el sentido y un
+// número de secuencia, así que un frame reenviado, reordenado o devuelto
+// al emisor no valida. No cifra: la confidencialidad la da TLS.
+type sealedConn struct {
+	net.Conn
+	key        []byte
+	send, recv byte
+
+	sendSeq uint64 // Write va siempre bajo el lock de escritura del llamador
+	recvSeq uint64
+	pending []byte // resto del último frame leído
+}
+
+func (c *sealedConn) Write(p []byte) (int, error) {
+	frame := make([]byte, 0, len(p)+sha256.Size)
+	frame = append(frame, p...)
+	frame = append(frame, c.tag(c.send, c.sendSeq, p)...)
+	c.sendSeq++
+
+	if err := WriteFrame(c.Conn, frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *sealedConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		frame, err := ReadFrame(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		if len(frame) < sha256.Size {
+			return 0, fmt.Errorf("%w: frame sin HMAC", ErrUnauthorized)
+		}
+
+		data, tag := frame[:len(frame)-sha256.Size], frame[len(frame)-sha256.Size:]
+		if !hmac.Equal(tag, c.tag(c.recv, c.recvSeq, data)) {
+			return 0, fmt.Errorf("%w: frame %d alterado o fuera de orden", ErrUnauthorized, c.recvSeq)
+		}
+		c.recvSeq++
+		c.pending = data
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *sealedConn) tag(dir byte, seq uint64, data []byte) []byte {
+	var header [9]byte
+	header[0] = dir
+	binary.BigEndian.PutUint64(header[1:], seq)
+
+	h := hmac.New(sha256.New, c.key)
+	h.Write(header[:])
+	h.Write(data)
+	return h.Sum(nil)
+}
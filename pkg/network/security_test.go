@@ -0,0 +1,192 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// El nodo responde cada task con el usuario que pidieron como vecino
+func echoNode(t *testing.T, cfg ServerConfig) net.Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	go func() {
+		Serve(server, cfg, func(msg Envelope) Envelope {
+			return Envelope{Type: MsgTaskResult, TaskResult: &TaskResponse{
+				PartialNeighbors: []NeighborResult{{UserID: msg.Task.TargetUser}},
+			}}
+		})
+	}()
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestHandshakeAuth(t *testing.T) {
+	secret := NewAuthenticator([]byte("secreto"))
+	other := NewAuthenticator([]byte("otro"))
+
+	tests := []struct {
+		name         string
+		client, node *Authenticator
+		wantErr      error
+	}{
+		{"sin secreto", nil, nil, nil},
+		{"mismo secreto", secret, secret, nil},
+		{"secreto distinto", other, secret, ErrUnauthorized},
+		{"cliente sin firma", nil, secret, ErrUnauthorized},
+		// el nodo tiene que probar que también conoce el secreto
+		{"nodo sin secreto", secret, nil, ErrUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := echoNode(t, ServerConfig{Auth: tc.node})
+
+			conn, codec, err := ClientHandshake(raw, ClientConfig{Codecs: []string{CodecBinary}, Auth: tc.client})
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewConn(conn, codec)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			reply, err := c.Call(ctx, Envelope{Type: MsgTask, Task: &TaskRequest{Shard: "user-0", TargetUser: "42"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reply.TaskResult == nil || len(reply.TaskResult.PartialNeighbors) != 1 || reply.TaskResult.PartialNeighbors[0].UserID != "42" {
+				t.Errorf("reply = %+v", reply)
+			}
+		})
+	}
+}
+
+// Un hello firmado no sirve en otra conexión: el nonce del nodo cambia
+func TestHandshakeReplay(t *testing.T) {
+	auth := NewAuthenticator([]byte("secreto"))
+
+	// Captura el hello que manda un cliente legítimo
+	client, fake := net.Pipe()
+	go ClientHandshake(client, ClientConfig{Auth: auth})
+
+	if err := writeJSONFrame(fake, Challenge{Version: ProtocolVersion, Nonce: newNonce()}); err != nil {
+		t.Fatal(err)
+	}
+	var hello Hello
+	if err := readJSONFrame(fake, &hello); err != nil {
+		t.Fatal(err)
+	}
+	fake.Close()
+
+	// y lo reenvía a un nodo real
+	attacker, node := net.Pipe()
+	defer attacker.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := ServerHandshake(node, ServerConfig{Auth: auth})
+		done <- err
+	}()
+
+	var challenge Challenge
+	if err := readJSONFrame(attacker, &challenge); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSONFrame(attacker, hello); err != nil {
+		t.Fatal(err)
+	}
+	var ack HelloAck
+	if err := readJSONFrame(attacker, &ack); err != nil {
+		t.Fatal(err)
+	}
+
+	if ack.Accepted {
+		t.Error("el nodo aceptó un hello repetido")
+	}
+	if err := <-done; !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestSealedFrames(t *testing.T) {
+	auth := NewAuthenticator([]byte("secreto"))
+	sn, cn := newNonce(), newNonce()
+
+	// Frames que el cliente manda, tal como viajan por el socket
+	a, b := net.Pipe()
+	client := auth.seal(a, sn, cn, false)
+	go func() {
+		client.Write([]byte("uno"))
+		client.Write([]byte("dos"))
+		a.Close()
+	}()
+	first, err := ReadFrame(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ReadFrame(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append([]byte(nil), second...)
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name   string
+		server bool // del lado del nodo (recibe lo del cliente)
+		frames [][]byte
+		want   string // lo que se lee antes del error
+	}{
+		{"en orden", true, [][]byte{first, second}, "unodos"},
+		{"alterado", true, [][]byte{first, tampered}, "uno"},
+		{"repetido", true, [][]byte{first, first}, "uno"},
+		{"reordenado", true, [][]byte{second, first}, ""},
+		{"devuelto al cliente", false, [][]byte{first}, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			in, out := net.Pipe()
+			defer out.Close()
+			go func() {
+				for _, f := range tc.frames {
+					WriteFrame(in, f)
+				}
+				in.Close()
+			}()
+
+			conn := auth.seal(out, sn, cn, tc.server)
+			var got []byte
+			var err error
+			buf := make([]byte, 16)
+			for {
+				var n int
+				n, err = conn.Read(buf)
+				if err != nil {
+					break
+				}
+				got = append(got, buf[:n]...)
+			}
+
+			if string(got) != tc.want {
+				t.Errorf("leído %q, want %q", got, tc.want)
+			}
+			complete := tc.want == "unodos"
+			if complete && errors.Is(err, ErrUnauthorized) {
+				t.Errorf("err = %v con frames válidos", err)
+			}
+			if !complete && !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("err = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
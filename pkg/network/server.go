@@ -0,0 +1,60 @@
+package network
+
+import (
+	"net"
+	"sync"
+)
+
+// -------------------- Lado nodo: atender una conexión --------------------
+
+// Handler procesa una petición y devuelve la respuesta (el ID lo pone
+// Serve). Para errores usar ErrorReply.
+type Handler func(msg Envelope) Envelope
+
+// Serve hace el handshake según cfg y atiende una conexión persistente: lee
+// envelopes en bucle, procesa cada uno en su propia goroutine y devuelve
+// las respuestas con el mismo ID, en el orden en que terminen. Retorna
+// cuando el cliente cierra o si la versión no es compatible.
+func Serve(raw net.Conn, cfg ServerConfig, handle Handler) error {
+	defer raw.Close()
+
+	conn, codec, err := ServerHandshake(raw, cfg)
+	if err != nil {
+		return err
+	}
+
+	enc := codec.NewEncoder(conn)
+	dec := codec.NewDecoder(conn)
+
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		var msg Envelope
+		if err := dec.Decode(&msg); err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func(msg Envelope) {
+			defer wg.Done()
+
+			var reply Envelope
+			if msg.Version != ProtocolVersion {
+				reply = ErrorReply(ErrCodeVersion, "mensaje v%d, se requiere v%d", msg.Version, ProtocolVersion)
+			} else {
+				reply = handle(msg)
+			}
+			reply.ID = msg.ID
+			reply.Version = ProtocolVersion
+
+			writeMu.Lock()
+			defer writeMu.Unlock()
+
+			if err := enc.Encode(reply); err != nil {
+				conn.Close()
+			}
+		}(msg)
+	}
+}
@@ -0,0 +1,116 @@
+package partition
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// -----------------------------------------------------------
+// Consistent hashing con nodos virtuales
+// -----------------------------------------------------------
+
+// Ring asigna claves (usuarios) a nodos de forma determinista: la misma
+// clave cae siempre en el mismo nodo y, al agregar o quitar un nodo, sólo
+// se mueve ~1/N de las claves.
+type Ring struct {
+	vnodes int
+	points []uint64          // posiciones ordenadas en el anillo
+	owners map[uint64]string // posición -> nodo
+	nodes  []string
+}
+
+// NewRing construye el anillo con vnodes puntos por nodo
+func NewRing(nodes []string, vnodes int) *Ring {
+	if vnodes < 1 {
+		vnodes = 1
+	}
+
+	r := &Ring{
+		vnodes: vnodes,
+		owners: make(map[uint64]string, len(nodes)*vnodes),
+		nodes:  append([]string(nil), nodes...),
+	}
+	sort.Strings(r.nodes)
+
+	for _, node := range r.nodes {
+		for i := 0; i < vnodes; i++ {
+			p := Hash(vnodeKey(node, i))
+			if _, taken := r.owners[p]; taken {
+				continue // colisión: gana el primer nodo en orden
+			}
+			r.owners[p] = node
+			r.points = append(r.points, p)
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Nodes devuelve los nodos del anillo ordenados
+func (r *Ring) Nodes() []string {
+	return r.nodes
+}
+
+// Owner devuelve el nodo responsable de key ("" si el anillo está vacío)
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	return r.owners[r.points[r.search(Hash(key))]]
+}
+
+// Successors devuelve node seguido de los n-1 nodos distintos que le
+// siguen en el anillo a partir de su posición base. Se usa para elegir
+// las réplicas del shard de un nodo.
+func (r *Ring) Successors(node string, n int) []string {
+	if n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+	if n <= 0 || len(r.points) == 0 {
+		return nil
+	}
+
+	out := []string{node}
+	seen := map[string]bool{node: true}
+
+	start := r.search(Hash(vnodeKey(node, 0)))
+	for i := 0; len(out) < n && i < len(r.points); i++ {
+		owner := r.owners[r.points[(start+i)%len(r.points)]]
+		if !seen[owner] {
+			seen[owner] = true
+			out = append(out, owner)
+		}
+	}
+	return out
+}
+
+// search devuelve el índice del primer punto >= h (con vuelta al inicio)
+func (r *Ring) search(h uint64) int {
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return i
+}
+
+// Hash es FNV-1a de 64 bits (estable entre procesos y reinicios) con un
+// mezclado final, porque las claves cortas y parecidas ("1", "2", ...)
+// quedarían agrupadas en el anillo.
+func Hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func vnodeKey(node string, i int) string {
+	return node + "#" + strconv.Itoa(i)
+}
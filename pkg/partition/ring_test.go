@@ -0,0 +1,130 @@
+package partition
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func nodeNames(n int) []string {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("nodo%d:9000", i+1)
+	}
+	return nodes
+}
+
+func TestOwnerDeterministic(t *testing.T) {
+	nodes := nodeNames(4)
+	a := NewRing(nodes, 64)
+	// mismo conjunto en otro orden: el anillo no depende del orden de alta
+	b := NewRing([]string{nodes[2], nodes[0], nodes[3], nodes[1]}, 64)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint(i)
+		if a.Owner(key) != b.Owner(key) {
+			t.Fatalf("Owner(%s) = %s y %s", key, a.Owner(key), b.Owner(key))
+		}
+	}
+
+	for _, node := range nodes {
+		if fmt.Sprint(a.Successors(node, 3)) != fmt.Sprint(b.Successors(node, 3)) {
+			t.Errorf("Successors(%s) = %v y %v", node, a.Successors(node, 3), b.Successors(node, 3))
+		}
+	}
+}
+
+// El hash no puede cambiar entre versiones ni procesos: el API y los
+// nodos tienen que ubicar a cada usuario en el mismo lugar
+func TestHashStable(t *testing.T) {
+	tests := []struct {
+		key  string
+		want uint64
+	}{
+		{"1", 0x7c3832dde020d3d6},
+		{"nodo1:9000#0", 0xb8cbd6cfed0c7c8d},
+	}
+	for _, tc := range tests {
+		if got := Hash(tc.key); got != tc.want {
+			t.Errorf("Hash(%q) = %#x, want %#x", tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestSuccessorsDistinct(t *testing.T) {
+	nodes := nodeNames(5)
+	r := NewRing(nodes, 64)
+
+	tests := []struct {
+		n, want int
+	}{
+		{1, 1},
+		{3, 3},
+		{5, 5},
+		{8, 5}, // no hay más réplicas que nodos
+		{0, 0},
+	}
+	for _, tc := range tests {
+		for _, node := range nodes {
+			got := r.Successors(node, tc.n)
+			if len(got) != tc.want {
+				t.Fatalf("Successors(%s, %d) = %v, want %d nodos", node, tc.n, got, tc.want)
+			}
+			if tc.want > 0 && got[0] != node {
+				t.Errorf("Successors(%s, %d) no empieza por el nodo: %v", node, tc.n, got)
+			}
+
+			seen := map[string]bool{}
+			for _, s := range got {
+				if seen[s] {
+					t.Errorf("Successors(%s, %d) repite %s: %v", node, tc.n, s, got)
+				}
+				seen[s] = true
+			}
+		}
+	}
+}
+
+func TestEmptyRing(t *testing.T) {
+	r := NewRing(nil, 64)
+	if owner := r.Owner("1"); owner != "" {
+		t.Errorf("Owner en anillo vacío = %q", owner)
+	}
+	if s := r.Successors("nodo1:9000", 2); s != nil {
+		t.Errorf("Successors en anillo vacío = %v", s)
+	}
+}
+
+// Al pasar de N a N+1 nodos sólo deben moverse las claves que toma el
+// nodo nuevo: ~1/(N+1) del total, y ninguna entre nodos viejos
+func TestAddNodeMovesFraction(t *testing.T) {
+	const keys = 10000
+
+	for _, n := range []int{2, 4, 8} {
+		t.Run(fmt.Sprint(n, "->", n+1), func(t *testing.T) {
+			nodes := nodeNames(n + 1)
+			before := NewRing(nodes[:n], 128)
+			after := NewRing(nodes, 128)
+			added := nodes[n]
+
+			moved := 0
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprint(i)
+				from, to := before.Owner(key), after.Owner(key)
+				if from == to {
+					continue
+				}
+				if to != added {
+					t.Fatalf("clave %s pasó de %s a %s (nodo viejo)", key, from, to)
+				}
+				moved++
+			}
+
+			want := 1 / float64(n+1)
+			got := float64(moved) / keys
+			if math.Abs(got-want) > want*0.25 {
+				t.Errorf("se movió %.3f de las claves, want %.3f ± 25%%", got, want)
+			}
+		})
+	}
+}